@@ -5,17 +5,19 @@ go 1.23.0
 toolchain go1.24.5
 
 require (
+	github.com/fsnotify/fsnotify v1.9.0
 	github.com/prashantv/gostub v1.1.0
 	github.com/spf13/afero v1.14.0
 	github.com/stretchr/testify v1.11.0
+	golang.org/x/mod v0.27.0
 	golang.org/x/tools v0.36.0
 )
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
-	golang.org/x/mod v0.27.0 // indirect
 	golang.org/x/sync v0.16.0 // indirect
+	golang.org/x/sys v0.35.0 // indirect
 	golang.org/x/text v0.23.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
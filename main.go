@@ -1,29 +1,77 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/lonegunmanb/gophon/pkg"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "serve":
+			runServe(os.Args[2:])
+			return
+		case "verify":
+			runVerify(os.Args[2:])
+			return
+		case "index":
+			runIndex(os.Args[2:])
+			return
+		case "watch":
+			runWatch(os.Args[2:])
+			return
+		}
+	}
+
+	// Indexing is the default command
+	runIndex(os.Args[1:])
+}
+
+// runIndex implements the "index" subcommand, which writes index files for a module, its dependencies
+// or the standard library
+func runIndex(args []string) {
+	indexFlags := flag.NewFlagSet("index", flag.ExitOnError)
 	var (
-		pkgPath    = flag.String("pkg", "", "Package path to scan (e.g., 'testharness' or '' for root)")
-		basePkgUrl = flag.String("base", "", "Base package URL (e.g., 'github.com/lonegunmanb/gophon/pkg')")
-		destDir    = flag.String("dest", "./index", "Destination directory for generated index files")
-		help       = flag.Bool("help", false, "Show help message")
+		pkgPath    = indexFlags.String("pkg", "", "Package path to scan (e.g., 'testharness' or '' for root)")
+		basePkgUrl = indexFlags.String("base", "", "Base package URL (e.g., 'github.com/lonegunmanb/gophon/pkg'); detected from go.mod when empty")
+		rootDir    = indexFlags.String("root", "", "Directory package paths are relative to (default: current directory)")
+		destDir    = indexFlags.String("dest", "./index", "Destination directory for generated index files")
+		noDocs     = indexFlags.Bool("no-doc-comments", false, "Leave doc comments out of generated index files")
+		deps       = indexFlags.Bool("deps", false, "Index the module's dependencies from the local module cache into module@version subtrees")
+		std        = indexFlags.Bool("std", false, "Index the standard library of the active toolchain into a std@<version> subtree")
+		moduleAt   = indexFlags.String("module", "", "Module to index from a module zip or GOPROXY directory, as module@version")
+		zipPath    = indexFlags.String("zip", "", "Module zip in the 'go mod download' format to index with -module")
+		proxy      = indexFlags.String("proxy", "", "file:// GOPROXY directory to read the -module zip from")
+		rev        = indexFlags.String("rev", "", "Git revision (tag, branch or commit) to index without checking it out")
+		force      = indexFlags.Bool("force", false, "Re-index every package, even when its sources are unchanged since the last run")
+		dryRun     = indexFlags.Bool("dry-run", false, "List stale index files that would be pruned instead of removing them")
+		keepGoing  = indexFlags.Bool("keep-going", false, "Index every package that can be indexed when others fail, reporting failures in errors.json")
+		syntaxOnly = indexFlags.Bool("syntax-only", false, "Parse sources directly, without the Go toolchain or downloaded dependencies")
+		strict     = indexFlags.Bool("strict", false, "Fail packages that have syntax or type errors instead of indexing the declarations that parsed")
+		writers    = indexFlags.Int("write-workers", 0, "Goroutines writing index files concurrently (default: one per scanning worker)")
+		failExit   = indexFlags.Int("failure-exit-code", 1, "Exit code when packages fail to index; 0 treats failures as warnings")
+		help       = indexFlags.Bool("help", false, "Show help message")
 	)
 
-	flag.Usage = func() {
+	indexFlags.Usage = func() {
 		_, _ = fmt.Fprintf(os.Stderr, "gophon - Go Project Code Indexing Tool\n\n")
-		_, _ = fmt.Fprintf(os.Stderr, "Usage: %s [options]\n\n", os.Args[0])
+		_, _ = fmt.Fprintf(os.Stderr, "Usage: %s [index] [options]\n", os.Args[0])
+		_, _ = fmt.Fprintf(os.Stderr, "       %s serve --mcp [options]\n", os.Args[0])
+		_, _ = fmt.Fprintf(os.Stderr, "       %s watch [options]\n", os.Args[0])
+		_, _ = fmt.Fprintf(os.Stderr, "       %s verify [options]\n\n", os.Args[0])
 		_, _ = fmt.Fprintf(os.Stderr, "Options:\n")
-		flag.PrintDefaults()
+		indexFlags.PrintDefaults()
 		_, _ = fmt.Fprintf(os.Stderr, "\nEnvironment Variables:\n")
 		_, _ = fmt.Fprintf(os.Stderr, "  GOPHON_CPU_LIMIT    Limit CPU usage percentage (1-100, default: 100)\n")
 		_, _ = fmt.Fprintf(os.Stderr, "                      Lower values reduce CPU usage but increase processing time\n")
@@ -33,23 +81,68 @@ func main() {
 		_, _ = fmt.Fprintf(os.Stderr, "  %s -base=github.com/lonegunmanb/gophon/pkg -dest=./output\n\n", os.Args[0])
 		_, _ = fmt.Fprintf(os.Stderr, "  # Index a specific package\n")
 		_, _ = fmt.Fprintf(os.Stderr, "  %s -pkg=testharness -base=github.com/lonegunmanb/gophon/pkg -dest=./output\n\n", os.Args[0])
+		_, _ = fmt.Fprintf(os.Stderr, "  # Index a module checked out elsewhere, reading the module path from its go.mod\n")
+		_, _ = fmt.Fprintf(os.Stderr, "  %s -root=/path/to/checkout -dest=./output\n\n", os.Args[0])
+		_, _ = fmt.Fprintf(os.Stderr, "  # Index the dependencies of the module in the current directory from the module cache\n")
+		_, _ = fmt.Fprintf(os.Stderr, "  %s index --deps -dest=./output\n\n", os.Args[0])
+		_, _ = fmt.Fprintf(os.Stderr, "  # Index the standard library, or only the net/http subtree of it\n")
+		_, _ = fmt.Fprintf(os.Stderr, "  %s index --std -dest=./output\n", os.Args[0])
+		_, _ = fmt.Fprintf(os.Stderr, "  %s index --std -pkg=net/http -dest=./output\n\n", os.Args[0])
+		_, _ = fmt.Fprintf(os.Stderr, "  # Index a tagged release of the repository without touching the working tree\n")
+		_, _ = fmt.Fprintf(os.Stderr, "  %s index --rev v1.4.0 -dest=./output\n\n", os.Args[0])
+		_, _ = fmt.Fprintf(os.Stderr, "  # Index a released module version from a GOPROXY directory without checking it out\n")
+		_, _ = fmt.Fprintf(os.Stderr, "  %s index -module=github.com/spf13/afero@v1.14.0 -proxy=file:///srv/goproxy -dest=./output\n\n", os.Args[0])
 		_, _ = fmt.Fprintf(os.Stderr, "  # Index with CPU throttling (50%% CPU usage)\n")
 		_, _ = fmt.Fprintf(os.Stderr, "  GOPHON_CPU_LIMIT=50 %s -base=github.com/lonegunmanb/gophon/pkg -dest=./output\n\n", os.Args[0])
+		_, _ = fmt.Fprintf(os.Stderr, "  # Serve generated index files to AI agents over MCP (stdio)\n")
+		_, _ = fmt.Fprintf(os.Stderr, "  %s serve --mcp -index=./output -base=github.com/lonegunmanb/gophon/pkg\n\n", os.Args[0])
+		_, _ = fmt.Fprintf(os.Stderr, "  # Keep index files up to date while editing, re-indexing only changed packages\n")
+		_, _ = fmt.Fprintf(os.Stderr, "  %s watch -dest=./output\n\n", os.Args[0])
+		_, _ = fmt.Fprintf(os.Stderr, "  # Check that every generated index file parses as Go\n")
+		_, _ = fmt.Fprintf(os.Stderr, "  %s verify -dest=./output\n\n", os.Args[0])
 	}
 
-	flag.Parse()
+	_ = indexFlags.Parse(args)
 
 	if *help {
-		flag.Usage()
+		indexFlags.Usage()
 		os.Exit(0)
 	}
 
-	if *basePkgUrl == "" {
-		_, _ = fmt.Fprintf(os.Stderr, "Error: -base flag is required\n\n")
-		flag.Usage()
+	modes := 0
+	for _, enabled := range []bool{*deps, *std, *moduleAt != "", *rev != ""} {
+		if enabled {
+			modes++
+		}
+	}
+	if modes > 1 {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: -deps, -std, -module and -rev cannot be combined\n\n")
+		indexFlags.Usage()
 		os.Exit(1)
 	}
 
+	var modulePath, moduleVersion string
+	if *moduleAt != "" {
+		var ok bool
+		modulePath, moduleVersion, ok = strings.Cut(*moduleAt, "@")
+		if !ok || modulePath == "" || moduleVersion == "" || (*zipPath == "") == (*proxy == "") {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: -module takes module@version and exactly one of -zip or -proxy\n\n")
+			indexFlags.Usage()
+			os.Exit(1)
+		}
+	}
+
+	opts := pkg.Options{
+		ExcludeDocComments: *noDocs,
+		Root:               *rootDir,
+		Force:              *force,
+		DryRun:             *dryRun,
+		KeepGoing:          *keepGoing,
+		Strict:             *strict,
+		SyntaxOnly:         *syntaxOnly,
+		WriteWorkers:       *writers,
+	}
+
 	// Convert destination path to absolute path
 	absDestDir, err := filepath.Abs(*destDir)
 	if err != nil {
@@ -58,8 +151,27 @@ func main() {
 
 	fmt.Printf("Gophon Code Indexer\n")
 	fmt.Printf("===================\n")
+	if *rootDir != "" {
+		fmt.Printf("Root: %s\n", *rootDir)
+	}
 	fmt.Printf("Package path: %s\n", *pkgPath)
-	fmt.Printf("Base URL: %s\n", *basePkgUrl)
+	if *basePkgUrl != "" {
+		fmt.Printf("Base URL: %s\n", *basePkgUrl)
+	} else {
+		fmt.Printf("Base URL: (detected from go.mod)\n")
+	}
+	if *deps {
+		fmt.Printf("Mode: dependencies from the module cache\n")
+	}
+	if *std {
+		fmt.Printf("Mode: standard library\n")
+	}
+	if *moduleAt != "" {
+		fmt.Printf("Mode: module %s from archive\n", *moduleAt)
+	}
+	if *rev != "" {
+		fmt.Printf("Mode: git revision %s\n", *rev)
+	}
 	fmt.Printf("Destination: %s\n", absDestDir)
 	
 	// Show CPU throttling status
@@ -74,9 +186,45 @@ func main() {
 	// Track start time for elapsed time and ETA calculations
 	startTime := time.Now()
 	
+	// Packages indexed despite syntax or type errors, for the summary
+	var packagesWithErrors []string
+
 	// Create progress callback with rich visual feedback
 	progressCallback := func(progress pkg.ProgressInfo) {
 		elapsed := time.Since(startTime)
+
+		// Report problems that did not stop indexing, and the stale index files pruned once a module is indexed
+		for _, warning := range progress.Warnings {
+			fmt.Printf("\n⚠️  Warning: %s\n", warning)
+		}
+		for _, filePath := range progress.Pruned {
+			if *dryRun {
+				fmt.Printf("Would remove %s\n", filePath)
+			} else {
+				fmt.Printf("Removed %s\n", filePath)
+			}
+		}
+		if len(progress.Warnings) > 0 || len(progress.Pruned) > 0 {
+			return
+		}
+
+		// Report index file names that several symbols of the package would have overwritten
+		for _, collision := range progress.Collisions {
+			fmt.Printf("\n⚠️  %d symbols in package %s share index file name %s, written as %s\n",
+				len(collision.Files), progress.Current, collision.IndexFile, strings.Join(collision.Files, ", "))
+		}
+
+		// Report the errors of packages indexed with the declarations that parsed
+		if len(progress.Errors) > 0 {
+			fmt.Printf("\n⚠️  %s has %d error(s):\n", progress.Current, len(progress.Errors))
+			for _, loadErr := range progress.Errors {
+				fmt.Printf("     %s\n", loadErr)
+			}
+			packagesWithErrors = append(packagesWithErrors, progress.Current)
+		}
+		if len(progress.Errors) > 0 || len(progress.Collisions) > 0 {
+			return
+		}
 		
 		// Calculate ETA
 		var eta time.Duration
@@ -130,17 +278,87 @@ func main() {
 		}
 	}
 
-	// Call IndexSourceCode with progress callback
-	err = pkg.IndexSourceCode(*pkgPath, *basePkgUrl, absDestDir, progressCallback)
-	if err != nil {
+	// Stop on Ctrl+C or SIGTERM, keeping the manifests of the packages indexed so far;
+	// a second signal terminates right away
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	if *rev != "" {
+		// Index the git revision from the repository's object store with progress callback
+		err = pkg.IndexRevision(ctx, *rev, *pkgPath, *basePkgUrl, absDestDir, opts, progressCallback)
+	} else if *moduleAt != "" {
+		// Index the module zip, located in the GOPROXY directory when one is given
+		archive := *zipPath
+		if *proxy != "" {
+			archive, err = pkg.ModuleZipFromProxy(*proxy, modulePath, moduleVersion)
+		}
+		if err == nil {
+			err = pkg.IndexModuleZip(ctx, archive, modulePath, moduleVersion, absDestDir, opts, progressCallback)
+		}
+	} else if *std {
+		// Index the standard library of the active toolchain with progress callback
+		var toolchain pkg.Toolchain
+		toolchain, err = pkg.FindToolchain()
+		if err == nil {
+			err = pkg.IndexStandardLibrary(ctx, toolchain, *pkgPath, absDestDir, opts, progressCallback)
+		}
+	} else if *deps {
+		// Index every dependency found in the module cache with progress callback
+		var dependencies, missing []pkg.Dependency
+		dependencies, missing, err = pkg.FindDependencies(*rootDir)
+		for _, dep := range missing {
+			fmt.Printf("⚠️  Warning: module %s@%s is not in the module cache, skipped\n", dep.Path, dep.Version)
+		}
+		if err == nil {
+			err = pkg.IndexDependencies(ctx, dependencies, *rootDir, absDestDir, opts, progressCallback)
+		}
+	} else {
+		// Call IndexSourceCodeContext with progress callback
+		err = pkg.IndexSourceCodeContext(ctx, *pkgPath, *basePkgUrl, absDestDir, opts, progressCallback)
+	}
+	stop()
+	if errors.Is(err, context.Canceled) {
+		_, _ = fmt.Fprintf(os.Stderr, "\nInterrupted: the index files and manifest written so far are kept in %s\n", absDestDir)
+		os.Exit(130)
+	}
+	failures := pkg.PackageErrors(err)
+	if len(failures) > 0 {
+		// Packages that failed to index exit with the configured code
+		_, _ = fmt.Fprintln(os.Stderr)
+		for _, failure := range failures {
+			_, _ = fmt.Fprintf(os.Stderr, "❌ %v\n", failure)
+		}
+		_, _ = fmt.Fprintf(os.Stderr, "%d package(s) failed to index\n", len(failures))
+		if *keepGoing && (*deps || *std || *moduleAt != "") {
+			_, _ = fmt.Fprintf(os.Stderr, "Failures are reported in the errors.json of each module@version subtree of %s\n", absDestDir)
+		} else if *keepGoing {
+			_, _ = fmt.Fprintf(os.Stderr, "Failures are reported in %s\n", filepath.Join(absDestDir, "errors.json"))
+		}
+		if *failExit != 0 {
+			os.Exit(*failExit)
+		}
+	} else if err != nil {
 		log.Fatalf("Failed to generate index files: %v", err)
 	}
 
 	// Calculate final statistics
 	elapsed := time.Since(startTime)
 	
-	fmt.Printf("✅ Index generation completed successfully!\n")
+	if len(failures) > 0 {
+		fmt.Printf("⚠️  Index generation completed with %d failure(s)\n", len(failures))
+	} else {
+		fmt.Printf("✅ Index generation completed successfully!\n")
+	}
 	fmt.Printf("📊 Summary:\n")
 	fmt.Printf("   • Total time: %.1fs\n", elapsed.Seconds())
 	fmt.Printf("   • Output directory: %s\n", absDestDir)
+	if len(packagesWithErrors) > 0 {
+		fmt.Printf("   • Packages indexed despite errors: %d (use -strict to fail them)\n", len(packagesWithErrors))
+		for _, pkgUrl := range packagesWithErrors {
+			fmt.Printf("     - %s\n", pkgUrl)
+		}
+	}
 }
@@ -0,0 +1,87 @@
+package pkg
+
+import (
+	"context"
+	"path/filepath"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// defaultLoadBatchSize is the number of packages loaded per packages.Load call when scanning recursively.
+// One go command run per batch instead of per package saves its startup and the repeated loading of
+// shared dependencies, while batches keep the syntax trees held in memory at a time bounded.
+const defaultLoadBatchSize = 128
+
+// scanJob is a package handed to a scanning worker
+type scanJob struct {
+	pkgPath string            // Package directory relative to the root
+	skipped bool              // Whether the package is unchanged since the last run and is not scanned
+	loaded  *packages.Package // The package preloaded with its batch, nil when it is loaded on its own
+}
+
+// scanResult is a scanned package handed from the extract workers to the sink workers
+type scanResult struct {
+	packageInfo *PackageInfo
+	pkgUrl      string // Full package URL
+}
+
+// loadScanJobs prepares the jobs of a batch of packages. Packages that are not skipped are loaded
+// with a single packages.Load call when they are loaded by the go command.
+func loadScanJobs(ctx context.Context, pkgPaths []string, opts Options) []scanJob {
+	jobs := make([]scanJob, 0, len(pkgPaths))
+	var toLoad []string
+	for _, pkgPath := range pkgPaths {
+		skipped := opts.skipPackage != nil && opts.skipPackage(pkgPath)
+		jobs = append(jobs, scanJob{pkgPath: pkgPath, skipped: skipped})
+		if !skipped {
+			toLoad = append(toLoad, pkgPath)
+		}
+	}
+
+	// Packages parsed from sourceFs are cheap to load one at a time
+	if opts.SyntaxOnly || !isOsFs(sourceFs) || len(toLoad) == 0 {
+		return jobs
+	}
+	loaded := loadPackageBatch(ctx, toLoad, opts)
+	for i := range jobs {
+		jobs[i].loaded = loaded[jobs[i].pkgPath]
+	}
+	return jobs
+}
+
+// loadPackageBatch loads the packages at the given relative paths with one packages.Load call and returns
+// them by relative path. Packages it cannot match to their directory are left out, and so is the whole
+// batch when the go command fails, so that each is loaded and reports its failure on its own.
+func loadPackageBatch(ctx context.Context, pkgPaths []string, opts Options) map[string]*packages.Package {
+	cfg, patterns, err := packagesConfig(ctx, opts, pkgPaths...)
+	if err != nil {
+		return nil
+	}
+	pkgs, err := packages.Load(cfg, patterns...)
+	if err != nil {
+		return nil
+	}
+
+	root := opts.Root
+	if root == "" {
+		root = "."
+	}
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil
+	}
+	byDir := make(map[string]*packages.Package, len(pkgs))
+	for _, pkg := range pkgs {
+		if pkg.Dir != "" {
+			byDir[filepath.Clean(pkg.Dir)] = pkg
+		}
+	}
+
+	loaded := make(map[string]*packages.Package, len(pkgPaths))
+	for _, pkgPath := range pkgPaths {
+		if pkg, ok := byDir[filepath.Join(absRoot, filepath.FromSlash(pkgPath))]; ok {
+			loaded[pkgPath] = pkg
+		}
+	}
+	return loaded
+}
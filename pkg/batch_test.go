@@ -0,0 +1,79 @@
+package pkg
+
+import (
+	"context"
+	"io/fs"
+	"sync/atomic"
+	"testing"
+
+	"github.com/prashantv/gostub"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndexSourceCode_BatchedLoadMatchesPerPackageLoad(t *testing.T) {
+	perPackageFs := afero.NewMemMapFs()
+	batchedFs := afero.NewMemMapFs()
+	stubs := gostub.Stub(&destFs, perPackageFs)
+	defer stubs.Reset()
+
+	// Count the packages loaded on their own rather than with their batch
+	var singleLoads atomic.Int32
+	stubs.Stub(&scanPackage, func(ctx context.Context, pkgPath, basePkgUrl string, opts Options) (*PackageInfo, error) {
+		singleLoads.Add(1)
+		return ScanSinglePackageContext(ctx, pkgPath, basePkgUrl, opts)
+	})
+
+	require.NoError(t, IndexSourceCodeWithOptions("testharness", "github.com/lonegunmanb/gophon/pkg", "output", Options{loadBatchSize: 1}, nil))
+	stubs.Stub(&destFs, batchedFs)
+	require.NoError(t, IndexSourceCodeWithOptions("testharness", "github.com/lonegunmanb/gophon/pkg", "output", Options{}, nil))
+	assert.Zero(t, singleLoads.Load())
+
+	require.NoError(t, afero.Walk(perPackageFs, "output", func(filePath string, info fs.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		expected, err := afero.ReadFile(perPackageFs, filePath)
+		require.NoError(t, err)
+		actual, err := afero.ReadFile(batchedFs, filePath)
+		require.NoError(t, err, filePath)
+		assert.Equal(t, string(expected), string(actual), filePath)
+		return nil
+	}))
+}
+
+func TestLoadScanJobs_FailedBatchFallsBackToSingleLoads(t *testing.T) {
+	// The go command fails for the whole batch when it is interrupted
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	jobs := loadScanJobs(ctx, []string{"testharness/sub_pkg", "testharness/enums"}, Options{})
+	require.Len(t, jobs, 2)
+	for _, job := range jobs {
+		assert.Nil(t, job.loaded, job.pkgPath)
+	}
+
+	jobs = loadScanJobs(context.Background(), []string{"testharness/sub_pkg", "testharness/enums"}, Options{})
+	for _, job := range jobs {
+		assert.NotNil(t, job.loaded, job.pkgPath)
+	}
+}
+
+func benchmarkScanPackagesRecursively(b *testing.B, opts Options) {
+	for i := 0; i < b.N; i++ {
+		err := ScanPackagesRecursivelyWithOptions("", "github.com/lonegunmanb/gophon/pkg", opts, func(*PackageInfo, string) {}, nil)
+		require.NoError(b, err)
+	}
+}
+
+func BenchmarkScanPackagesRecursively_PerPackageLoad(b *testing.B) {
+	benchmarkScanPackagesRecursively(b, Options{loadBatchSize: 1})
+}
+
+func BenchmarkScanPackagesRecursively_BatchedLoad(b *testing.B) {
+	benchmarkScanPackagesRecursively(b, Options{})
+}
+
+func BenchmarkScanPackagesRecursively_SyntaxOnly(b *testing.B) {
+	benchmarkScanPackagesRecursively(b, Options{SyntaxOnly: true})
+}
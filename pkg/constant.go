@@ -9,12 +9,45 @@ import (
 type ConstantInfo struct {
 	*Range
 	*ast.GenDecl
-	Name string
+	Spec          *ast.ValueSpec
+	Name          string
+	Value         string // Constant value computed by go/types, empty when type information is unavailable
+	Type          string // Constant type computed by go/types, e.g. Kind or untyped int
+	indexFileName string // disambiguated index file name, set when the predictable name collides
 }
 
 // IndexFileName generates a predictable index file name for this constant
 // Returns a file name in the format: var.<ConstantName>.goindex
 // Uses 'var' prefix to simplify AI agent lookups (same as variables)
 func (c *ConstantInfo) IndexFileName() string {
+	if c.indexFileName != "" {
+		return c.indexFileName
+	}
 	return fmt.Sprintf("var.%s.goindex", c.Name)
 }
+
+// Imports returns only the import declarations referenced by this constant's declaration
+func (c *ConstantInfo) Imports() string {
+	return valueSpecImports(c.Range, c.GenDecl, c.Spec)
+}
+
+// enclosingDecl returns the declaration containing this constant and the range of its snippet
+func (c *ConstantInfo) enclosingDecl() (*ast.GenDecl, *Range) {
+	return c.GenDecl, c.Range
+}
+
+// setIndexFileName overrides the predictable index file name after a collision
+func (c *ConstantInfo) setIndexFileName(name string) {
+	c.indexFileName = name
+}
+
+// ValueComment returns a comment stating the computed value and type of a constant whose snippet
+// covers a whole const group, e.g. "// KindB = 1 (type Kind)". Such constants are declared through
+// iota or implicit repetition, so their value is not evident from the source. It returns an empty
+// string otherwise or when the value is unknown.
+func (c *ConstantInfo) ValueComment() string {
+	if c.Value == "" || c.Range == nil || c.GenDecl == nil || len(c.GenDecl.Specs) < 2 || !c.Range.covers(c.GenDecl) {
+		return ""
+	}
+	return fmt.Sprintf("// %s = %s (type %s)\n", c.Name, c.Value, c.Type)
+}
@@ -95,3 +95,45 @@ func TestConstantInfo_IndexFileName(t *testing.T) {
 		})
 	}
 }
+
+func TestScanPackage_EnumConstantsCarryTheirGroup(t *testing.T) {
+	result, err := ScanSinglePackage("testharness/enums", "github.com/lonegunmanb/gophon/pkg")
+	require.NoError(t, err)
+
+	kindB := findConstantByName(result.Constants, "KindB")
+	require.NotNil(t, kindB)
+	assert.Equal(t, `// Kinds of things, testing iota with implicit repetition.
+const (
+	KindA Kind = iota
+	KindB
+	KindC
+)`, kindB.String())
+	assert.Equal(t, "1", kindB.Value)
+	assert.Equal(t, "Kind", kindB.Type)
+	assert.Equal(t, "// KindB = 1 (type Kind)\n", kindB.ValueComment())
+	assert.Equal(t, "", kindB.Imports())
+
+	content := generateIndexContent(kindB, "")
+	assert.Contains(t, content, "\tKindC\n)\n\n// KindB = 1 (type Kind)\n")
+	assert.NotContains(t, content, "const (\n// Kinds", "A group already covering its declaration must not be wrapped again")
+
+	mb := findConstantByName(result.Constants, "MB")
+	require.NotNil(t, mb)
+	assert.Contains(t, mb.String(), "KB = 1 << (10 * (iota + 1))")
+	assert.Equal(t, "// MB = 1048576 (type untyped int)\n", mb.ValueComment())
+
+	// Values are exact, neither truncated nor rounded
+	bannerB := findConstantByName(result.Constants, "BannerB")
+	require.NotNil(t, bannerB)
+	assert.Equal(t, "// BannerB = \"This banner is long enough that a shortened form of its value would be truncated, B\" (type string)\n", bannerB.ValueComment())
+	twoMoles := findConstantByName(result.Constants, "TwoMoles")
+	require.NotNil(t, twoMoles)
+	assert.Equal(t, "// TwoMoles = 1204428152000000000000000 (type untyped float)\n", twoMoles.ValueComment())
+
+	// Groups without iota or implicit repetition keep single-spec snippets
+	shortTimeout := findConstantByName(result.Constants, "ShortTimeout")
+	require.NotNil(t, shortTimeout)
+	assert.Equal(t, "\tShortTimeout = time.Second", shortTimeout.String())
+	assert.Equal(t, "", shortTimeout.ValueComment())
+	assert.Equal(t, `import "time"`, shortTimeout.Imports())
+}
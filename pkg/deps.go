@@ -0,0 +1,150 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Dependency is a module in the build list of the main module, located in the local module cache
+type Dependency struct {
+	Path    string // Module path, e.g. github.com/spf13/afero
+	Version string // Selected version, e.g. v1.14.0
+	Dir     string // Directory holding the module's source, in the module cache or a local replacement
+}
+
+// listedModule mirrors the fields of `go list -m -json` output used by FindDependencies
+type listedModule struct {
+	Path    string
+	Version string
+	Dir     string
+	Main    bool
+	Replace *listedModule
+}
+
+// FindDependencies resolves the requirement graph of the module in root from its go.mod and go.sum
+// and returns every required module whose source is in the local module cache.
+// The network is never used and go.mod and go.sum are never updated; modules that have not been
+// downloaded are returned separately as missing, without a Dir, so callers can warn about them.
+func FindDependencies(root string) (deps []Dependency, missing []Dependency, err error) {
+	cmd := exec.Command("go", "list", "-m", "-json", "all")
+	cmd.Dir = root
+	cmd.Env = goListEnv(os.Environ())
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list modules: %w: %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(output))
+	for {
+		var module listedModule
+		if err := decoder.Decode(&module); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, nil, fmt.Errorf("failed to decode module list: %w", err)
+		}
+		if module.Main {
+			continue
+		}
+
+		dir := module.Dir
+		if module.Replace != nil {
+			dir = module.Replace.Dir
+		}
+		if dir == "" {
+			// Modules only needed for the module graph are not downloaded
+			missing = append(missing, Dependency{Path: module.Path, Version: module.Version})
+			continue
+		}
+		deps = append(deps, Dependency{Path: module.Path, Version: module.Version, Dir: dir})
+	}
+	return deps, missing, nil
+}
+
+// goListEnv returns environ with the network disabled and -mod=readonly added to the GOFLAGS the user set
+func goListEnv(environ []string) []string {
+	env := make([]string, 0, len(environ)+2)
+	goFlags := "-mod=readonly"
+	for _, kv := range environ {
+		if value, ok := strings.CutPrefix(kv, "GOFLAGS="); ok {
+			if value = strings.TrimSpace(value); value != "" {
+				goFlags = value + " " + goFlags
+			}
+			continue
+		}
+		env = append(env, kv)
+	}
+	return append(env, "GOPROXY=off", "GOFLAGS="+goFlags)
+}
+
+// IndexDependencies indexes each dependency into a module@version subtree of destFolder, such as
+// github.com/spf13/afero@v1.14.0, using the build list of the module in root to resolve imports.
+// The root manifest of destFolder links each dependency's manifest.
+//
+// When ctx is done, indexing stops after linking the dependencies indexed so far and ctx.Err() is returned.
+// A dependency with packages that fail to index stops the run, unless opts.KeepGoing is set: every
+// dependency is then indexed, the failures are reported in the errors.json of each dependency's
+// subtree, and the returned error joins them.
+func IndexDependencies(ctx context.Context, deps []Dependency, root, destFolder string, opts Options, progressCallback func(ProgressInfo)) error {
+	if root == "" {
+		root = "."
+	}
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+
+	var links []ManifestModule
+	var cancelErr error
+	var failures []error
+	for _, dep := range deps {
+		if cancelErr = ctx.Err(); cancelErr != nil {
+			break
+		}
+
+		depOpts := opts
+		depOpts.Root = dep.Dir
+		depOpts.moduleRoot = absRoot
+		depDir := dep.Path + "@" + dep.Version
+		depDestFolder := filepath.Join(destFolder, filepath.FromSlash(depDir))
+
+		manifest, err := indexModule(ctx, "", dep.Path, depDestFolder, dep.Dir, depOpts, progressCallback)
+		// A cancelled module is linked with the packages indexed so far
+		if cancelErr = ctx.Err(); cancelErr == nil {
+			if err = reportFailures(depDestFolder, err, opts); err != nil {
+				failures = append(failures, fmt.Errorf("failed to index module %s: %w", depDir, err))
+			}
+		}
+		// In keep-going mode, the packages indexed despite failures are linked too
+		if manifest != nil {
+			if err := saveManifest(depDestFolder, manifest); err != nil {
+				return errors.Join(append(failures, err)...)
+			}
+			links = append(links, ManifestModule{
+				Path:     dep.Path,
+				Version:  dep.Version,
+				Dir:      depDir,
+				Manifest: path.Join(depDir, manifestFileName),
+			})
+		}
+		if cancelErr != nil || (len(failures) > 0 && !opts.KeepGoing) {
+			break
+		}
+	}
+
+	err = errors.Join(append([]error{cancelErr}, failures...)...)
+	if len(links) == 0 {
+		return err
+	}
+	return errors.Join(err, linkModules(destFolder, links))
+}
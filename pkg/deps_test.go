@@ -0,0 +1,161 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/prashantv/gostub"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindDependencies(t *testing.T) {
+	goMod, err := os.ReadFile("../go.mod")
+	require.NoError(t, err)
+	goSum, err := os.ReadFile("../go.sum")
+	require.NoError(t, err)
+
+	deps, missing, err := FindDependencies("..")
+	require.NoError(t, err)
+
+	// Modules that are not downloaded are returned apart, without a directory
+	for _, dep := range missing {
+		assert.Empty(t, dep.Dir)
+		assert.NotEmpty(t, dep.Version)
+	}
+
+	var aferoDep *Dependency
+	for i, dep := range deps {
+		assert.NotEqual(t, "github.com/lonegunmanb/gophon", dep.Path, "The main module is not a dependency")
+		assert.NotEmpty(t, dep.Dir)
+		if dep.Path == "github.com/spf13/afero" {
+			aferoDep = &deps[i]
+		}
+	}
+	require.NotNil(t, aferoDep)
+	assert.Equal(t, "v1.14.0", aferoDep.Version)
+
+	// Listing dependencies has no side effects on the module
+	content, err := os.ReadFile("../go.mod")
+	require.NoError(t, err)
+	assert.Equal(t, string(goMod), string(content))
+	content, err = os.ReadFile("../go.sum")
+	require.NoError(t, err)
+	assert.Equal(t, string(goSum), string(content))
+}
+
+func TestGoListEnv_KeepsUserGoFlags(t *testing.T) {
+	env := goListEnv([]string{"HOME=/home/u", "GOFLAGS=-tags=integration", "GOPROXY=https://proxy.golang.org"})
+	assert.Equal(t, []string{"HOME=/home/u", "GOPROXY=https://proxy.golang.org", "GOPROXY=off", "GOFLAGS=-tags=integration -mod=readonly"}, env)
+
+	env = goListEnv([]string{"HOME=/home/u"})
+	assert.Equal(t, []string{"HOME=/home/u", "GOPROXY=off", "GOFLAGS=-mod=readonly"}, env)
+}
+
+func TestIndexDependencies(t *testing.T) {
+	stub := gostub.Stub(&destFs, afero.NewMemMapFs())
+	defer stub.Reset()
+
+	deps, _, err := FindDependencies("..")
+	require.NoError(t, err)
+	var gostubDeps []Dependency
+	for _, dep := range deps {
+		if dep.Path == "github.com/prashantv/gostub" {
+			gostubDeps = append(gostubDeps, dep)
+		}
+	}
+	require.Len(t, gostubDeps, 1)
+
+	require.NoError(t, IndexDependencies(context.Background(), gostubDeps, "..", "output", Options{}, nil))
+
+	content, err := afero.ReadFile(destFs, "output/github.com/prashantv/gostub@v1.1.0/func.Stub.goindex")
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "// Import path: github.com/prashantv/gostub\n")
+	assert.Contains(t, string(content), "func Stub(varToStub interface{}, stubVal interface{}) *Stubs {")
+
+	content, err = afero.ReadFile(destFs, "output/manifest.json")
+	require.NoError(t, err)
+	var rootManifest Manifest
+	require.NoError(t, json.Unmarshal(content, &rootManifest))
+	assert.Equal(t, []ManifestModule{{
+		Path:     "github.com/prashantv/gostub",
+		Version:  "v1.1.0",
+		Dir:      "github.com/prashantv/gostub@v1.1.0",
+		Manifest: "github.com/prashantv/gostub@v1.1.0/manifest.json",
+	}}, rootManifest.Modules)
+}
+
+func TestIndexDependencies_Cancelled(t *testing.T) {
+	srcFs := afero.NewMemMapFs()
+	stubs := gostub.Stub(&destFs, afero.NewMemMapFs())
+	stubs.Stub(&sourceFs, srcFs)
+	defer stubs.Reset()
+
+	require.NoError(t, afero.WriteFile(srcFs, "/cache/one/go.mod", []byte("module example.com/one\n"), 0644))
+	require.NoError(t, afero.WriteFile(srcFs, "/cache/one/a/a.go", []byte("package a\n\nfunc A() {}\n"), 0644))
+	require.NoError(t, afero.WriteFile(srcFs, "/cache/one/b/b.go", []byte("package b\n\nfunc B() {}\n"), 0644))
+	require.NoError(t, afero.WriteFile(srcFs, "/cache/two/go.mod", []byte("module example.com/two\n"), 0644))
+	require.NoError(t, afero.WriteFile(srcFs, "/cache/two/two.go", []byte("package two\n\nfunc Two() {}\n"), 0644))
+	deps := []Dependency{
+		{Path: "example.com/one", Version: "v1.0.0", Dir: "/cache/one"},
+		{Path: "example.com/two", Version: "v1.0.0", Dir: "/cache/two"},
+	}
+
+	// The run is interrupted while the second module loads
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stubs.Stub(&scanPackage, func(ctx context.Context, pkgPath, basePkgUrl string, opts Options) (*PackageInfo, error) {
+		if basePkgUrl == "example.com/two" {
+			cancel()
+			return nil, ctx.Err()
+		}
+		return ScanSinglePackageContext(ctx, pkgPath, basePkgUrl, opts)
+	})
+
+	err := IndexDependencies(ctx, deps, "/cache/one", "output", Options{}, nil)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Empty(t, PackageErrors(err))
+
+	// The modules indexed before the interruption are linked
+	content, err := afero.ReadFile(destFs, "output/manifest.json")
+	require.NoError(t, err)
+	var rootManifest Manifest
+	require.NoError(t, json.Unmarshal(content, &rootManifest))
+	require.Len(t, rootManifest.Modules, 1)
+	assert.Equal(t, "example.com/one", rootManifest.Modules[0].Path)
+	exists, err := afero.Exists(destFs, "output/example.com/one@v1.0.0/b/func.B.goindex")
+	require.NoError(t, err)
+	assert.True(t, exists)
+	exists, err = afero.Exists(destFs, "output/example.com/two@v1.0.0/manifest.json")
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestLinkModules_KeepsExistingManifest(t *testing.T) {
+	stub := gostub.Stub(&destFs, afero.NewMemMapFs())
+	defer stub.Reset()
+
+	existing := &Manifest{
+		Package: "example.com/app",
+		Path:    ".",
+		Modules: []ManifestModule{{Path: "example.com/lib", Version: "v1.0.0", Dir: "example.com/lib@v1.0.0"}},
+	}
+	require.NoError(t, saveManifest("output", existing))
+
+	require.NoError(t, linkModules("output", []ManifestModule{
+		{Path: "example.com/lib", Version: "v1.0.0", Dir: "example.com/lib@v1.0.0", Manifest: "example.com/lib@v1.0.0/manifest.json"},
+		{Path: "example.com/dep", Version: "v0.2.0", Dir: "example.com/dep@v0.2.0", Manifest: "example.com/dep@v0.2.0/manifest.json"},
+	}))
+
+	content, err := afero.ReadFile(destFs, "output/manifest.json")
+	require.NoError(t, err)
+	var rootManifest Manifest
+	require.NoError(t, json.Unmarshal(content, &rootManifest))
+	assert.Equal(t, "example.com/app", rootManifest.Package)
+	require.Len(t, rootManifest.Modules, 2)
+	assert.Equal(t, "example.com/dep@v0.2.0", rootManifest.Modules[0].Dir)
+	assert.Equal(t, "example.com/lib@v1.0.0/manifest.json", rootManifest.Modules[1].Manifest)
+}
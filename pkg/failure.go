@@ -0,0 +1,88 @@
+package pkg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/afero"
+)
+
+// Phases of indexing a package, as recorded in PackageError
+const (
+	PhaseLoad    = "load"    // Loading and type-checking the package's sources
+	PhaseExtract = "extract" // Extracting symbols from the loaded package
+	PhaseWrite   = "write"   // Writing index files and the package manifest
+)
+
+// errorReportFileName is the name of the failure report written into the destination folder with Options.KeepGoing
+const errorReportFileName = "errors.json"
+
+// PackageError records a package that failed to index and the phase it failed in
+type PackageError struct {
+	Package string `json:"package"` // Full package URL
+	Phase   string `json:"phase"`   // PhaseLoad, PhaseExtract or PhaseWrite
+	Message string `json:"message"` // Error message
+	Err     error  `json:"-"`
+}
+
+// newPackageError wraps err as a failure of the package in the given phase
+func newPackageError(pkgUrl, phase string, err error) *PackageError {
+	return &PackageError{Package: pkgUrl, Phase: phase, Message: err.Error(), Err: err}
+}
+
+func (e *PackageError) Error() string {
+	return fmt.Sprintf("failed to %s package %s: %s", e.Phase, e.Package, e.Message)
+}
+
+func (e *PackageError) Unwrap() error {
+	return e.Err
+}
+
+// PackageErrors returns every PackageError held by err, which may join or wrap several errors
+func PackageErrors(err error) []*PackageError {
+	var failures []*PackageError
+	var walk func(err error)
+	walk = func(err error) {
+		switch e := err.(type) {
+		case nil:
+		case *PackageError:
+			failures = append(failures, e)
+		case interface{ Unwrap() []error }:
+			for _, inner := range e.Unwrap() {
+				walk(inner)
+			}
+		default:
+			walk(errors.Unwrap(err))
+		}
+	}
+	walk(err)
+	return failures
+}
+
+// saveErrorReport writes the package failures held by err to errors.json in destFolder,
+// or removes the report of a previous run when there are none
+func saveErrorReport(destFolder string, err error) error {
+	filePath := filepath.Join(destFolder, errorReportFileName)
+	failures := PackageErrors(err)
+	if len(failures) == 0 {
+		if removeErr := destFs.Remove(filePath); removeErr != nil && !errors.Is(removeErr, os.ErrNotExist) {
+			return removeErr
+		}
+		return nil
+	}
+
+	content, marshalErr := json.MarshalIndent(failures, "", "  ")
+	if marshalErr != nil {
+		return fmt.Errorf("failed to encode error report for %s: %w", destFolder, marshalErr)
+	}
+	if mkdirErr := destFs.MkdirAll(destFolder, 0700); mkdirErr != nil {
+		return fmt.Errorf("failed to create directory %s: %w", destFolder, mkdirErr)
+	}
+	if writeErr := afero.WriteFile(destFs, filePath, content, 0600); writeErr != nil {
+		return fmt.Errorf("failed to write error report %s: %w", filePath, writeErr)
+	}
+	return nil
+}
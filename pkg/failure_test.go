@@ -0,0 +1,148 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/prashantv/gostub"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndexSourceCode_KeepGoing(t *testing.T) {
+	srcFs := afero.NewMemMapFs()
+	stubs := gostub.Stub(&destFs, afero.NewMemMapFs())
+	stubs.Stub(&sourceFs, srcFs)
+	defer stubs.Reset()
+
+	require.NoError(t, afero.WriteFile(srcFs, "/src/go.mod", []byte("module example.com/kg\n"), 0644))
+	require.NoError(t, afero.WriteFile(srcFs, "/src/a/a.go", []byte("package a\n\nfunc A() {}\n"), 0644))
+	require.NoError(t, afero.WriteFile(srcFs, "/src/b/b.go", []byte("package b\n\nfunc B() {}\n"), 0644))
+	require.NoError(t, afero.WriteFile(srcFs, "/src/c/c.go", []byte("package c\n\nfunc C() {}\n"), 0644))
+
+	// Packages b and c fail to load
+	broken := map[string]bool{"b": true, "c": true}
+	stubs.Stub(&scanPackage, func(ctx context.Context, pkgPath, basePkgUrl string, opts Options) (*PackageInfo, error) {
+		if broken[pkgPath] {
+			return nil, errors.New("broken package")
+		}
+		return ScanSinglePackageContext(ctx, pkgPath, basePkgUrl, opts)
+	})
+
+	// Every failure is reported, and by default the run writes no manifest
+	err := IndexSourceCodeWithOptions("", "", "output", Options{Root: "/src"}, nil)
+	failures := PackageErrors(err)
+	require.Len(t, failures, 2)
+	assert.ElementsMatch(t, []string{"example.com/kg/b", "example.com/kg/c"}, []string{failures[0].Package, failures[1].Package})
+	assert.Equal(t, PhaseLoad, failures[0].Phase)
+	assert.Contains(t, err.Error(), "failed to load package example.com/kg/b: broken package")
+	for _, file := range []string{"output/manifest.json", "output/errors.json"} {
+		exists, err := afero.Exists(destFs, file)
+		require.NoError(t, err)
+		assert.False(t, exists, file)
+	}
+
+	// Keep-going writes what it can and reports the failures
+	err = IndexSourceCodeWithOptions("", "", "output", Options{Root: "/src", KeepGoing: true}, nil)
+	assert.Len(t, PackageErrors(err), 2)
+	assertRootManifestPackages(t, "example.com/kg/a")
+	content, err := afero.ReadFile(destFs, "output/errors.json")
+	require.NoError(t, err)
+	var report []PackageError
+	require.NoError(t, json.Unmarshal(content, &report))
+	require.Len(t, report, 2)
+	assert.Equal(t, PhaseLoad, report[0].Phase)
+	assert.Equal(t, "broken package", report[0].Message)
+
+	// A run without failures removes the report
+	broken = nil
+	require.NoError(t, IndexSourceCodeWithOptions("", "", "output", Options{Root: "/src", KeepGoing: true}, nil))
+	assertRootManifestPackages(t, "example.com/kg/a", "example.com/kg/b", "example.com/kg/c")
+	exists, err := afero.Exists(destFs, "output/errors.json")
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestWritePackageIndex_ReportsWriteFailures(t *testing.T) {
+	stubs := gostub.Stub(&destFs, afero.NewReadOnlyFs(afero.NewMemMapFs()))
+	defer stubs.Reset()
+
+	pkgInfo, err := ScanSinglePackage("testharness/sub_pkg", "github.com/lonegunmanb/gophon/pkg")
+	require.NoError(t, err)
+	manifest, err := writePackageIndex(pkgInfo, pkgInfo.PkgPath, "github.com/lonegunmanb/gophon/pkg", "output", ".", Options{})
+	assert.Nil(t, manifest)
+
+	failures := PackageErrors(err)
+	require.Len(t, failures, 1)
+	assert.Equal(t, PhaseWrite, failures[0].Phase)
+	assert.Equal(t, "github.com/lonegunmanb/gophon/pkg/testharness/sub_pkg", failures[0].Package)
+}
+
+func TestSaveIndexes_ReportsEveryFailure(t *testing.T) {
+	stubs := gostub.Stub(&destFs, afero.NewReadOnlyFs(afero.NewMemMapFs()))
+	defer stubs.Reset()
+
+	pkgInfo, err := ScanSinglePackage("testharness/collisions", "github.com/lonegunmanb/gophon/pkg")
+	require.NoError(t, err)
+	require.Len(t, pkgInfo.Functions, 2)
+
+	// The directory cannot be created for either file, and both failures are returned
+	err = saveIndexes("output/collisions", pkgInfo.Functions, "")
+	joined, ok := err.(interface{ Unwrap() []error })
+	require.True(t, ok)
+	assert.Len(t, joined.Unwrap(), 2)
+}
+
+func TestIndexDependencies_KeepGoing(t *testing.T) {
+	srcFs := afero.NewMemMapFs()
+	stubs := gostub.Stub(&destFs, afero.NewMemMapFs())
+	stubs.Stub(&sourceFs, srcFs)
+	defer stubs.Reset()
+
+	require.NoError(t, afero.WriteFile(srcFs, "/cache/bad/go.mod", []byte("module example.com/bad\n"), 0644))
+	require.NoError(t, afero.WriteFile(srcFs, "/cache/bad/a/a.go", []byte("package a\n\nfunc A() {}\n"), 0644))
+	require.NoError(t, afero.WriteFile(srcFs, "/cache/bad/b/b.go", []byte("package b\n\nfunc B() {}\n"), 0644))
+	require.NoError(t, afero.WriteFile(srcFs, "/cache/good/go.mod", []byte("module example.com/good\n"), 0644))
+	require.NoError(t, afero.WriteFile(srcFs, "/cache/good/good.go", []byte("package good\n\nfunc Good() {}\n"), 0644))
+	deps := []Dependency{
+		{Path: "example.com/bad", Version: "v1.0.0", Dir: "/cache/bad"},
+		{Path: "example.com/good", Version: "v1.0.0", Dir: "/cache/good"},
+	}
+
+	stubs.Stub(&scanPackage, func(ctx context.Context, pkgPath, basePkgUrl string, opts Options) (*PackageInfo, error) {
+		if pkgPath == "b" {
+			return nil, errors.New("broken package")
+		}
+		return ScanSinglePackageContext(ctx, pkgPath, basePkgUrl, opts)
+	})
+
+	// By default the failing module stops the run
+	err := IndexDependencies(context.Background(), deps, "/cache/good", "output", Options{}, nil)
+	failures := PackageErrors(err)
+	require.Len(t, failures, 1)
+	assert.Equal(t, "example.com/bad/b", failures[0].Package)
+	exists, err := afero.Exists(destFs, "output/example.com/good@v1.0.0/func.Good.goindex")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	// Keep-going indexes every module and reports the failures in the failing module's subtree
+	err = IndexDependencies(context.Background(), deps, "/cache/good", "output", Options{KeepGoing: true}, nil)
+	require.Len(t, PackageErrors(err), 1)
+	content, err := afero.ReadFile(destFs, "output/example.com/bad@v1.0.0/errors.json")
+	require.NoError(t, err)
+	var report []PackageError
+	require.NoError(t, json.Unmarshal(content, &report))
+	require.Len(t, report, 1)
+	assert.Equal(t, "example.com/bad/b", report[0].Package)
+	for _, file := range []string{"output/example.com/bad@v1.0.0/a/func.A.goindex", "output/example.com/good@v1.0.0/func.Good.goindex"} {
+		exists, err := afero.Exists(destFs, file)
+		require.NoError(t, err)
+		assert.True(t, exists, file)
+	}
+	exists, err = afero.Exists(destFs, "output/example.com/good@v1.0.0/errors.json")
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
@@ -1,22 +1,31 @@
 package pkg
 
 import (
+	"fmt"
 	"go/ast"
 	"go/parser"
 	"go/token"
-	"os"
+	"go/types"
+	"path"
+	"strconv"
 	"strings"
 	"sync"
+	"unicode"
+
+	"github.com/spf13/afero"
 )
 
 // FileInfo contains information about a single Go file
 type FileInfo struct {
 	*ast.File
-	FileName string
-	FilePath string
-	Package  string
-	content  *string      // cached file content
-	mu       sync.RWMutex // mutex for thread-safe cache access
+	FileName  string
+	FilePath  string
+	Package   string
+	content   *string      // cached file content
+	mu        sync.RWMutex // mutex for thread-safe cache access
+	fset      *token.FileSet
+	typesInfo *types.Info // type information of the enclosing package, nil when unavailable
+	fs        afero.Fs    // filesystem the file was loaded from, sourceFs when nil
 }
 
 // Imports returns the import statements of the file
@@ -56,6 +65,36 @@ func (f *FileInfo) PackagePath() string {
 	return f.Package
 }
 
+// PackageName returns the name declared in the file's package clause,
+// falling back to the last element of the package path when the file has not been parsed
+func (f *FileInfo) PackageName() string {
+	if f.File != nil && f.File.Name != nil {
+		return f.File.Name.Name
+	}
+	return path.Base(f.Package)
+}
+
+// source returns the file content between two positions, or an empty string when unavailable
+func (f *FileInfo) source(start, end token.Pos) string {
+	if f.fset == nil || !start.IsValid() || !end.IsValid() {
+		return ""
+	}
+	content := f.String()
+	startOffset, endOffset := f.fset.Position(start).Offset, f.fset.Position(end).Offset
+	if startOffset < 0 || endOffset > len(content) || startOffset > endOffset {
+		return ""
+	}
+	return content[startOffset:endOffset]
+}
+
+// line returns the 1-based line number of pos, or 0 when the position is unknown
+func (f *FileInfo) line(pos token.Pos) int {
+	if f.fset == nil || !pos.IsValid() {
+		return 0
+	}
+	return f.fset.Position(pos).Line
+}
+
 // String reads and returns the content of the file
 func (f *FileInfo) String() string {
 	// Check cache with read lock
@@ -76,8 +115,12 @@ func (f *FileInfo) String() string {
 		return *f.content
 	}
 
-	// Read file content
-	contentBytes, err := os.ReadFile(f.FileName)
+	// Read file content from the filesystem the file was loaded from
+	fs := f.fs
+	if fs == nil {
+		fs = sourceFs
+	}
+	contentBytes, err := afero.ReadFile(fs, f.FileName)
 	if err != nil {
 		return ""
 	}
@@ -88,3 +131,138 @@ func (f *FileInfo) String() string {
 
 	return contentStr
 }
+
+// importsFor returns the import declarations referenced by the given declaration nodes,
+// keeping each import in its original alias form. Dot imports are kept when the nodes use
+// an identifier they provide, and a blank "embed" import is kept for //go:embed directives.
+// Without type information package references are matched by name, and dot imports are always kept.
+// The same goes for code the type checker left unresolved, such as function literals passed to functions
+// of stubbed imports when a package is parsed without loading its imports.
+func (f *FileInfo) importsFor(nodes ...ast.Node) string {
+	if f.File == nil {
+		return f.Imports()
+	}
+
+	usedNames := make(map[string]bool)
+	dotPaths := make(map[string]bool)
+	usesEmbed := false
+	unresolved := false
+	for _, node := range nodes {
+		if node == nil {
+			continue
+		}
+		ast.Inspect(node, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.Comment:
+				if strings.HasPrefix(n.Text, "//go:embed") {
+					usesEmbed = true
+				}
+			case *ast.SelectorExpr:
+				ident, ok := n.X.(*ast.Ident)
+				if !ok {
+					return true
+				}
+				if f.typesInfo == nil {
+					usedNames[ident.Name] = true
+				} else if obj := f.typesInfo.Uses[ident]; obj == nil {
+					usedNames[ident.Name] = true
+					unresolved = true
+					return false
+				} else if pkgName, ok := obj.(*types.PkgName); ok {
+					usedNames[pkgName.Name()] = true
+					// The selected identifier belongs to the qualified package, not a dot import
+					return false
+				}
+			case *ast.Ident:
+				if f.typesInfo == nil {
+					return true
+				}
+				obj := f.typesInfo.Uses[n]
+				if _, defined := f.typesInfo.Defs[n]; obj == nil && !defined {
+					unresolved = true
+				}
+				if obj != nil && obj.Pkg() != nil && obj.Pkg().Path() != f.PackagePath() && obj.Parent() == obj.Pkg().Scope() {
+					dotPaths[obj.Pkg().Path()] = true
+				}
+			}
+			return true
+		})
+	}
+
+	// A blank "embed" import is redundant when the file also imports embed by name and the nodes use it
+	for _, spec := range f.File.Imports {
+		if spec.Path.Value == `"embed"` && (spec.Name == nil || spec.Name.Name != "_") && usedNames[f.importName(spec, "embed")] {
+			usesEmbed = false
+		}
+	}
+
+	var specs []string
+	for _, spec := range f.File.Imports {
+		importPath, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+
+		var keep bool
+		switch {
+		case spec.Name != nil && spec.Name.Name == "_":
+			keep = usesEmbed && importPath == "embed"
+		case spec.Name != nil && spec.Name.Name == ".":
+			keep = f.typesInfo == nil || unresolved || dotPaths[importPath]
+		default:
+			keep = usedNames[f.importName(spec, importPath)]
+		}
+		if !keep {
+			continue
+		}
+
+		if spec.Name != nil {
+			specs = append(specs, fmt.Sprintf("%s %s", spec.Name.Name, spec.Path.Value))
+		} else {
+			specs = append(specs, spec.Path.Value)
+		}
+	}
+
+	switch len(specs) {
+	case 0:
+		return ""
+	case 1:
+		return "import " + specs[0]
+	default:
+		return "import (\n\t" + strings.Join(specs, "\n\t") + "\n)"
+	}
+}
+
+// importName returns the name under which an import is referenced in this file
+func (f *FileInfo) importName(spec *ast.ImportSpec, importPath string) string {
+	if spec.Name != nil {
+		return spec.Name.Name
+	}
+	if f.typesInfo != nil {
+		if pkgName := f.typesInfo.PkgNameOf(spec); pkgName != nil {
+			return pkgName.Name()
+		}
+	}
+	return assumedPackageName(importPath)
+}
+
+// assumedPackageName guesses the package name of an import path the way goimports does:
+// the last path element, skipping a major version suffix and a "go-" prefix, cut at the first
+// character that is not valid in an identifier.
+func assumedPackageName(importPath string) string {
+	base := path.Base(importPath)
+	if strings.HasPrefix(base, "v") {
+		if _, err := strconv.Atoi(base[1:]); err == nil {
+			if dir := path.Dir(importPath); dir != "." {
+				base = path.Base(dir)
+			}
+		}
+	}
+	base = strings.TrimPrefix(base, "go-")
+	if i := strings.IndexFunc(base, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	}); i >= 0 {
+		base = base[:i]
+	}
+	return base
+}
@@ -2,6 +2,9 @@ package pkg
 
 import (
 	"github.com/stretchr/testify/require"
+	"go/ast"
+	"go/parser"
+	"go/token"
 	"os"
 	"path/filepath"
 	"testing"
@@ -102,3 +105,73 @@ func TestFileInfo_String(t *testing.T) {
 	content := directFileInfo.String()
 	assert.Equal(t, string(expectedContent), content, "String() method should return the same content as direct file reading")
 }
+
+func TestScanPackage_SymbolImportsArePruned(t *testing.T) {
+	packageResult := scanHarnessPackage(t)
+
+	newServiceFunc := findFunctionByName(packageResult.Functions, "NewService")
+	require.NotNil(t, newServiceFunc)
+	assert.Equal(t, "", newServiceFunc.Imports(), "NewService references no imported package")
+
+	createUserMethod := findMethodByNameAndReceiver(packageResult.Functions, "CreateUser", "*Service")
+	require.NotNil(t, createUserMethod)
+	assert.Equal(t, "import (\n\t\"context\"\n\t\"fmt\"\n)", createUserMethod.Imports())
+
+	defaultTimeoutConst := findConstantByName(packageResult.Constants, "DefaultTimeout")
+	require.NotNil(t, defaultTimeoutConst)
+	assert.Equal(t, `import "time"`, defaultTimeoutConst.Imports())
+
+	userServiceType := findTypeByName(packageResult.Types, "UserService")
+	require.NotNil(t, userServiceType)
+	assert.Equal(t, `import "context"`, userServiceType.Imports())
+
+	globalCounterVar := findVariableByName(packageResult.Variables, "GlobalCounter")
+	require.NotNil(t, globalCounterVar)
+	assert.Equal(t, "", globalCounterVar.Imports())
+}
+
+func TestFileInfo_importsFor_WithoutTypeInformation(t *testing.T) {
+	source := `package example
+
+import (
+	"embed"
+	_ "embed"
+	_ "net/http/pprof"
+	. "strings"
+	str "strconv"
+	"gopkg.in/yaml.v3"
+	"github.com/example/go-toolkit/v2"
+)
+
+//go:embed assets
+var assets embed.FS
+
+//go:embed version.txt
+var version string
+
+func Parse(s string) (int, error) {
+	var out map[string]any
+	_ = yaml.Unmarshal([]byte(ToUpper(s)), &out)
+	toolkit.Touch()
+	return str.Atoi(s)
+}
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "example.go", source, parser.ParseComments)
+	require.NoError(t, err)
+
+	fileInfo := &FileInfo{
+		File:    file,
+		Package: "github.com/example/example",
+		content: &source,
+	}
+
+	assetsDecl := file.Decls[1].(*ast.GenDecl)
+	assert.Equal(t, "import (\n\t\"embed\"\n\t. \"strings\"\n)", fileInfo.importsFor(assetsDecl.Specs[0], assetsDecl.Doc))
+
+	versionDecl := file.Decls[2].(*ast.GenDecl)
+	assert.Equal(t, "import (\n\t_ \"embed\"\n\t. \"strings\"\n)", fileInfo.importsFor(versionDecl.Specs[0], versionDecl.Doc))
+
+	parseFunc := file.Decls[3].(*ast.FuncDecl)
+	assert.Equal(t, "import (\n\t. \"strings\"\n\tstr \"strconv\"\n\t\"gopkg.in/yaml.v3\"\n\t\"github.com/example/go-toolkit/v2\"\n)", fileInfo.importsFor(parseFunc))
+}
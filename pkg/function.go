@@ -10,14 +10,19 @@ import (
 type FunctionInfo struct {
 	*Range
 	*ast.FuncDecl
-	Name         string
-	ReceiverType string
+	Name               string
+	ReceiverType       string
+	ReceiverTypeParams []string // Type parameter names of a generic receiver, e.g. [K V] for Pair[K, V]
+	indexFileName      string   // disambiguated index file name, set when the predictable name collides
 }
 
 // IndexFileName generates a predictable index file name for this function or method
 // For functions: func.<FunctionName>.goindex
 // For methods: method.<ReceiverType>.<MethodName>.goindex (strips * from pointer receivers)
 func (f *FunctionInfo) IndexFileName() string {
+	if f.indexFileName != "" {
+		return f.indexFileName
+	}
 	if f.ReceiverType == "" {
 		// Regular function
 		return fmt.Sprintf("func.%s.goindex", f.Name)
@@ -27,3 +32,35 @@ func (f *FunctionInfo) IndexFileName() string {
 		return fmt.Sprintf("method.%s.%s.goindex", receiverType, f.Name)
 	}
 }
+
+// Imports returns only the import declarations referenced by this function's declaration
+func (f *FunctionInfo) Imports() string {
+	if f.Range == nil || f.FileInfo == nil {
+		return ""
+	}
+	if f.FuncDecl == nil {
+		return f.FileInfo.Imports()
+	}
+	return f.FileInfo.importsFor(f.FuncDecl)
+}
+
+// Signature returns the function's declaration without doc comment and body, collapsed onto a single line,
+// e.g. "func (s *Service) GetUser(ctx context.Context, id int64) (*User, error)".
+// It returns an empty string when the source is unavailable.
+func (f *FunctionInfo) Signature() string {
+	if f.Range == nil || f.FileInfo == nil || f.FuncDecl == nil {
+		return ""
+	}
+
+	end := f.FuncDecl.End()
+	if f.FuncDecl.Body != nil {
+		end = f.FuncDecl.Body.Lbrace
+	}
+	source := f.FileInfo.source(f.FuncDecl.Pos(), end)
+	return strings.Join(strings.Fields(source), " ")
+}
+
+// setIndexFileName overrides the predictable index file name after a collision
+func (f *FunctionInfo) setIndexFileName(name string) {
+	f.indexFileName = name
+}
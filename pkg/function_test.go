@@ -33,7 +33,9 @@ func TestScanPackage_ExtractsFunctions(t *testing.T) {
 	assert.NotNil(t, newServiceFunc.FuncDecl)
 
 	// Assert String() method returns the exact source code from subjects.go
-	expectedNewServiceSource := `func NewService(userService UserService) *Service {
+	expectedNewServiceSource := `// NewService creates a new Service instance.
+// Testing standalone function extraction.
+func NewService(userService UserService) *Service {
 	return &Service{
 		userService: userService,
 	}
@@ -50,7 +52,9 @@ func TestScanPackage_ExtractsFunctions(t *testing.T) {
 	assert.NotNil(t, validateEmailFunc.FuncDecl)
 
 	// Assert String() method returns the exact source code from subjects.go
-	expectedValidateEmailSource := `func ValidateEmail(email string) bool {
+	expectedValidateEmailSource := `// ValidateEmail validates an email address format.
+// Testing standalone function with parameters and return values.
+func ValidateEmail(email string) bool {
 	return len(email) > 0 && contains(email, "@")
 }`
 	assert.Equal(t, expectedValidateEmailSource, validateEmailFunc.String(), "ValidateEmail String() should return exact source code")
@@ -65,7 +69,9 @@ func TestScanPackage_ExtractsFunctions(t *testing.T) {
 	assert.NotNil(t, containsFunc.FuncDecl)
 
 	// Assert String() method returns the exact source code from subjects.go
-	expectedContainsSource := `func contains(s, substr string) bool {
+	expectedContainsSource := `// contains is a helper function for string operations.
+// Testing private/unexported function extraction.
+func contains(s, substr string) bool {
 	for i := 0; i <= len(s)-len(substr); i++ {
 		if s[i:i+len(substr)] == substr {
 			return true
@@ -85,7 +91,9 @@ func TestScanPackage_ExtractsFunctions(t *testing.T) {
 	assert.NotNil(t, createUserMethod.FuncDecl)
 
 	// Assert String() method returns the exact source code from subjects.go
-	expectedCreateUserSource := `func (s *Service) CreateUser(ctx context.Context, name, email string) (*User, error) {
+	expectedCreateUserSource := `// CreateUser creates a new user.
+// Testing method extraction with receiver.
+func (s *Service) CreateUser(ctx context.Context, name, email string) (*User, error) {
 	if !ValidateEmail(email) {
 		return nil, fmt.Errorf("invalid email: %s", email)
 	}
@@ -109,7 +117,9 @@ func TestScanPackage_ExtractsFunctions(t *testing.T) {
 	assert.NotNil(t, getUserMethod.FuncDecl)
 
 	// Assert String() method returns the exact source code from subjects.go
-	expectedGetUserSource := `func (s *Service) GetUser(ctx context.Context, id int64) (*User, error) {
+	expectedGetUserSource := `// GetUser retrieves a user by ID.
+// Testing method with different parameter types.
+func (s *Service) GetUser(ctx context.Context, id int64) (*User, error) {
 	return s.userService.GetByID(ctx, id)
 }`
 	assert.Equal(t, expectedGetUserSource, getUserMethod.String(), "GetUser String() should return exact source code")
@@ -208,3 +218,38 @@ func findMethodByNameAndReceiver(functions []*FunctionInfo, name string, receive
 	}
 	return nil
 }
+
+func TestScanPackage_MethodsOnGenericTypes(t *testing.T) {
+	result, err := ScanSinglePackage("testharness/generics", "github.com/lonegunmanb/gophon/pkg")
+	require.NoError(t, err)
+
+	pushMethod := findMethodByNameAndReceiver(result.Functions, "Push", "*List")
+	require.NotNil(t, pushMethod, "Should recognise pointer receiver with a single type parameter")
+	assert.Equal(t, []string{"T"}, pushMethod.ReceiverTypeParams)
+	assert.Equal(t, "method.List.Push.goindex", pushMethod.IndexFileName())
+
+	lenMethod := findMethodByNameAndReceiver(result.Functions, "Len", "List")
+	require.NotNil(t, lenMethod, "Should recognise value receiver with a single type parameter")
+	assert.Equal(t, []string{"T"}, lenMethod.ReceiverTypeParams)
+
+	setMethod := findMethodByNameAndReceiver(result.Functions, "Set", "*Pair")
+	require.NotNil(t, setMethod, "Should recognise receiver with multiple type parameters")
+	assert.Equal(t, []string{"K", "V"}, setMethod.ReceiverTypeParams)
+	assert.Equal(t, "method.Pair.Set.goindex", setMethod.IndexFileName())
+
+	// The top-level Push function must keep its own index file
+	pushFunc := findFunctionByName(result.Functions, "Push")
+	require.NotNil(t, pushFunc)
+	assert.Equal(t, "func.Push.goindex", pushFunc.IndexFileName())
+	assert.Empty(t, pushFunc.ReceiverTypeParams)
+
+	listType := findTypeByName(result.Types, "List")
+	require.NotNil(t, listType)
+	assert.Equal(t, []string{"T"}, listType.TypeParams())
+	assert.Len(t, listType.Methods, 2)
+	assert.Contains(t, listType.MethodSet(), "//   func (l *List[T]) Push(v T) // method.List.Push.goindex\n")
+
+	pairType := findTypeByName(result.Types, "Pair")
+	require.NotNil(t, pairType)
+	assert.Equal(t, []string{"K", "V"}, pairType.TypeParams())
+}
@@ -0,0 +1,152 @@
+package pkg
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+// IndexRevision indexes the sources of a git revision (a tag, branch or commit) of the repository
+// containing opts.Root, without checking it out. The revision's tree is read through git plumbing
+// into an in-memory filesystem that serves as sourceFs while indexing, so the working tree is never
+// touched. The commit SHA is recorded in every index file and manifest. Indexing stops when ctx is done,
+// as with IndexSourceCodeContext.
+func IndexRevision(ctx context.Context, rev, pkgPath, basePkgUrl, destFolder string, opts Options, progressCallback func(ProgressInfo)) error {
+	root := opts.Root
+	if root == "" {
+		root = "."
+	}
+	sha, err := gitOutput(root, "rev-parse", "--verify", "--end-of-options", rev+"^{commit}")
+	if err != nil {
+		return fmt.Errorf("failed to resolve revision %s: %w", rev, err)
+	}
+	prefix, err := gitOutput(root, "rev-parse", "--show-prefix")
+	if err != nil {
+		return err
+	}
+
+	memFs := afero.NewMemMapFs()
+	memRoot := "/" + sha
+	if err := readGitTree(ctx, root, sha, memFs, memRoot); err != nil {
+		return err
+	}
+
+	revOpts := opts
+	revOpts.Root = filepath.Join(memRoot, filepath.FromSlash(prefix))
+	revOpts.Revision = sha
+	return withSourceFs(memFs, func() error {
+		return IndexSourceCodeContext(ctx, pkgPath, basePkgUrl, destFolder, revOpts, progressCallback)
+	})
+}
+
+// gitOutput runs git in dir and returns its trimmed standard output
+func gitOutput(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", strings.Join(args, " "), err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// readGitTree copies the files of the commit's tree that indexing reads into fs under root: Go files,
+// go.mod and go.work. Other blobs, symbolic links, submodules and directories skipped during package
+// discovery, such as vendor and testdata, are never read, so the tree is not held in memory.
+func readGitTree(ctx context.Context, dir, commit string, fs afero.Fs, root string) error {
+	listing, err := gitOutput(dir, "ls-tree", "-r", "-z", "--full-tree", commit)
+	if err != nil {
+		return err
+	}
+
+	type blob struct{ sha, path string }
+	var blobs []blob
+	for _, entry := range strings.Split(listing, "\x00") {
+		// Each entry reads "<mode> <type> <object>\t<path>"
+		meta, filePath, ok := strings.Cut(entry, "\t")
+		fields := strings.Fields(meta)
+		if !ok || len(fields) != 3 || fields[1] != "blob" || fields[0] == "120000" || !isIndexedTreeFile(filePath) {
+			continue
+		}
+		blobs = append(blobs, blob{sha: fields[2], path: filePath})
+	}
+	if len(blobs) == 0 {
+		return nil
+	}
+
+	// Stream every blob through a single cat-file process
+	cmd := exec.CommandContext(ctx, "git", "cat-file", "--batch")
+	cmd.Dir = dir
+	var request strings.Builder
+	for _, b := range blobs {
+		request.WriteString(b.sha + "\n")
+	}
+	cmd.Stdin = strings.NewReader(request.String())
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start git cat-file: %w", err)
+	}
+
+	reader := bufio.NewReader(stdout)
+	for _, b := range blobs {
+		content, err := readBatchObject(reader, b.sha)
+		if err != nil {
+			_ = cmd.Process.Kill()
+			_ = cmd.Wait()
+			return fmt.Errorf("failed to read %s at %s: %w", b.path, commit, err)
+		}
+		dest := filepath.Join(root, filepath.FromSlash(path.Clean(b.path)))
+		if err := fs.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return err
+		}
+		if err := afero.WriteFile(fs, dest, content, 0644); err != nil {
+			return err
+		}
+	}
+	return cmd.Wait()
+}
+
+// isIndexedTreeFile reports whether a file of a git tree, given by its slash-separated path, is read
+// when indexing
+func isIndexedTreeFile(filePath string) bool {
+	dir, name := path.Split(filePath)
+	for _, element := range strings.Split(strings.TrimSuffix(dir, "/"), "/") {
+		if element != "" && shouldSkipDirectory(element) {
+			return false
+		}
+	}
+	return name == "go.mod" || name == "go.work" || strings.HasSuffix(name, ".go")
+}
+
+// readBatchObject reads one object from `git cat-file --batch` output
+func readBatchObject(reader *bufio.Reader, sha string) ([]byte, error) {
+	header, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	// The header reads "<object> <type> <size>"
+	var object, objectType string
+	var size int
+	if _, err := fmt.Sscanf(header, "%s %s %d", &object, &objectType, &size); err != nil || object != sha {
+		return nil, fmt.Errorf("unexpected cat-file header %q", strings.TrimSpace(header))
+	}
+
+	content := make([]byte, size+1) // The object is followed by a newline
+	if _, err := io.ReadFull(reader, content); err != nil {
+		return nil, err
+	}
+	return content[:size], nil
+}
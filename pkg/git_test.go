@@ -0,0 +1,103 @@
+package pkg
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/prashantv/gostub"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndexRevision(t *testing.T) {
+	stub := gostub.Stub(&destFs, afero.NewMemMapFs())
+	defer stub.Reset()
+
+	repo := t.TempDir()
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = repo
+		output, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(output))
+	}
+	writeFile := func(name, content string) {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(repo, name)), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(repo, name), []byte(content), 0644))
+	}
+
+	git("init", "-q")
+	writeFile("go.mod", "module example.com/tagged\n\ngo 1.23\n")
+	writeFile("tagged.go", "package tagged\n\n// Released exists at v1.0.0\nfunc Released() {}\n")
+	writeFile("sub/sub.go", "package sub\n\n// Helper exists at v1.0.0\nfunc Helper() {}\n")
+	git("add", "-A")
+	git("commit", "-q", "-m", "v1")
+	git("tag", "v1.0.0")
+	sha, err := gitOutput(repo, "rev-parse", "HEAD")
+	require.NoError(t, err)
+
+	// Work in progress in the working tree must not leak into the index
+	writeFile("tagged.go", "package tagged\n\nfunc Unreleased() {}\n")
+
+	require.NoError(t, IndexRevision(context.Background(), "v1.0.0", "", "", "output", Options{Root: repo}, nil))
+
+	content, err := afero.ReadFile(destFs, "output/func.Released.goindex")
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "// Import path: example.com/tagged\n// Revision: "+sha+"\n\npackage tagged\n")
+
+	content, err = afero.ReadFile(destFs, "output/sub/func.Helper.goindex")
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "// Revision: "+sha+"\n")
+
+	exists, err := afero.Exists(destFs, "output/func.Unreleased.goindex")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	manifest, err := afero.ReadFile(destFs, "output/manifest.json")
+	require.NoError(t, err)
+	assert.Contains(t, string(manifest), `"revision": "`+sha+`"`)
+
+	// The working tree is left untouched
+	source, err := os.ReadFile(filepath.Join(repo, "tagged.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(source), "Unreleased")
+
+	assert.Error(t, IndexRevision(context.Background(), "v9.9.9", "", "", "output", Options{Root: repo}, nil))
+}
+
+func TestReadGitTree_OnlyReadsIndexedFiles(t *testing.T) {
+	repo := t.TempDir()
+	for name, content := range map[string]string{
+		"go.mod":                    "module example.com/tree\n",
+		"go.work":                   "go 1.23\n\nuse .\n",
+		"main.go":                   "package main\n",
+		"lib/lib.go":                "package lib\n",
+		"README.md":                 "# tree\n",
+		"assets/big.bin":            "binary",
+		"lib/testdata/fixture.go":   "package fixture\n",
+		"vendor/example.com/dep.go": "package dep\n",
+		".github/tool.go":           "package tool\n",
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(repo, name)), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(repo, name), []byte(content), 0644))
+	}
+	for _, args := range [][]string{{"init", "-q"}, {"add", "-A"}, {"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "tree"}} {
+		_, err := gitOutput(repo, args...)
+		require.NoError(t, err)
+	}
+
+	memFs := afero.NewMemMapFs()
+	require.NoError(t, readGitTree(context.Background(), repo, "HEAD", memFs, "/tree"))
+
+	var files []string
+	require.NoError(t, afero.Walk(memFs, "/tree", func(filePath string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			files = append(files, filepath.ToSlash(filePath))
+		}
+		return err
+	}))
+	assert.ElementsMatch(t, []string{"/tree/go.mod", "/tree/go.work", "/tree/main.go", "/tree/lib/lib.go"}, files)
+}
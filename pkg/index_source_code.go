@@ -1,9 +1,13 @@
 package pkg
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"path"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	"github.com/spf13/afero"
 )
@@ -13,6 +17,8 @@ var destFs = afero.NewOsFs()
 // IndexSourceCode recursively scans packages and generates index files for all indexable symbols.
 // It uses ScanPackagesRecursively to discover packages and generates individual .goindex files
 // for each symbol (constants, variables, types, functions, methods) in the destination filesystem.
+// A manifest.json listing the package's symbols is written next to each package's index files,
+// and a root manifest.json listing every package is written into destFolder.
 //
 // Parameters:
 //   - sourceFs: The source filesystem to scan (typically afero.NewOsFs() for real filesystem)
@@ -22,24 +28,269 @@ var destFs = afero.NewOsFs()
 //   - destFolder: The destination folder path where index files will be organized
 //   - progressCallback: Optional callback for progress updates, receives ProgressInfo
 func IndexSourceCode(pkgPath, basePkgUrl string, destFolder string, progressCallback func(ProgressInfo)) error {
+	return IndexSourceCodeWithOptions(pkgPath, basePkgUrl, destFolder, Options{}, progressCallback)
+}
+
+// IndexSourceCodeWithOptions behaves like IndexSourceCode, scanning and indexing packages with the given options.
+// When the whole tree is indexed and it is a workspace (a go.work file or nested modules), every module
+// is indexed under its own module path into a matching subdirectory of destFolder, and the root manifest
+// links the modules' manifests.
+func IndexSourceCodeWithOptions(pkgPath, basePkgUrl string, destFolder string, opts Options, progressCallback func(ProgressInfo)) error {
+	return IndexSourceCodeContext(context.Background(), pkgPath, basePkgUrl, destFolder, opts, progressCallback)
+}
+
+// IndexSourceCodeContext behaves like IndexSourceCodeWithOptions until ctx is done. It then stops scanning,
+// writes the manifests of the packages indexed so far and returns ctx.Err(). Stale index files are not
+// pruned on cancellation, as the packages that were not reached could not be checked.
+//
+// The returned error joins a PackageError for every package that failed to index. With opts.KeepGoing,
+// the packages indexed despite the failures keep their manifests and the failures are reported in an
+// errors.json file in destFolder.
+func IndexSourceCodeContext(ctx context.Context, pkgPath, basePkgUrl string, destFolder string, opts Options, progressCallback func(ProgressInfo)) error {
+	// Source files are recorded in manifests relative to the directory being scanned from
+	root := opts.Root
+	if root == "" {
+		root = "."
+	}
+	sourceRoot, err := filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+
+	if pkgPath == "" {
+		modules, err := FindModules(root)
+		if err != nil {
+			return err
+		}
+		if isWorkspace(root, modules) {
+			err := indexWorkspace(ctx, modules, basePkgUrl, destFolder, sourceRoot, opts, progressCallback)
+			return reportFailures(destFolder, err, opts)
+		}
+	}
+
+	basePkgUrl, err = resolveBasePkgUrl(basePkgUrl, opts)
+	if err != nil {
+		return err
+	}
+
+	// A cancelled run, or a run with failures in keep-going mode, still returns the manifest of what it indexed.
+	// A subtree run without symbols left drops the subtree's packages from the root manifest.
+	manifest, err := indexModule(ctx, pkgPath, basePkgUrl, destFolder, sourceRoot, opts, progressCallback)
+	if manifest != nil || err == nil {
+		if saveErr := saveRootManifest(destFolder, stateKey(pkgPath), manifest); saveErr != nil {
+			err = errors.Join(err, saveErr)
+		}
+	}
+	return reportFailures(destFolder, err, opts)
+}
+
+// reportFailures writes the package failures held by err to the error report of destFolder in keep-going mode
+// and returns err
+func reportFailures(destFolder string, err error, opts Options) error {
+	if !opts.KeepGoing || opts.DryRun {
+		return err
+	}
+	if reportErr := saveErrorReport(destFolder, err); reportErr != nil {
+		return errors.Join(err, reportErr)
+	}
+	return err
+}
+
+// indexWorkspace indexes every module into the subdirectory of destFolder matching its source directory
+// and writes a root manifest linking the modules' manifests
+func indexWorkspace(ctx context.Context, modules []ModuleInfo, basePkgUrl, destFolder, sourceRoot string, opts Options, progressCallback func(ProgressInfo)) error {
+	var root *Manifest
+	var links []ManifestModule
+	var cancelErr error
+	var failures []error
+	for _, module := range modules {
+		// A cancelled run links the modules indexed so far
+		if cancelErr = ctx.Err(); cancelErr != nil {
+			break
+		}
+
+		// An explicit base package URL overrides the detected import path of the root
+		if module.Dir == "." && basePkgUrl != "" {
+			module.Path = basePkgUrl
+		}
+
+		moduleOpts := opts
+		moduleOpts.Root = module.sourceDir(sourceRoot)
+		moduleDestFolder := filepath.Join(destFolder, filepath.FromSlash(module.Dir))
+
+		// Source files of modules outside the source root are recorded relative to the module
+		moduleSourceRoot := sourceRoot
+		if module.SourceDir != "" {
+			moduleSourceRoot = module.SourceDir
+		}
+		manifest, err := indexModule(ctx, "", module.Path, moduleDestFolder, moduleSourceRoot, moduleOpts, progressCallback)
+		if err != nil && ctx.Err() == nil {
+			if !opts.KeepGoing {
+				return fmt.Errorf("failed to index module %s: %w", module.Path, err)
+			}
+			failures = append(failures, err)
+		}
+		cancelErr = ctx.Err()
+		if manifest == nil {
+			continue
+		}
+
+		links = append(links, ManifestModule{
+			Path:     module.Path,
+			Dir:      module.Dir,
+			Manifest: path.Join(module.Dir, manifestFileName),
+		})
+		if module.Dir == "." {
+			// The root module's manifest shares the destination root with the workspace manifest
+			root = manifest
+			continue
+		}
+		if err := saveManifest(moduleDestFolder, manifest); err != nil {
+			return err
+		}
+	}
+
+	err := errors.Join(append([]error{cancelErr}, failures...)...)
+	if len(links) == 0 {
+		return err
+	}
+	if root == nil {
+		root = &Manifest{Path: ".", Revision: opts.Revision}
+	}
+	root.Modules = links
+	if saveErr := saveManifest(destFolder, root); saveErr != nil {
+		return errors.Join(err, saveErr)
+	}
+	return err
+}
+
+// indexModule writes the index files and package manifests of every package under pkgPath into destFolder
+// and returns the module's root manifest, or nil when no symbol was indexed. When ctx is done, it returns
+// the root manifest of the packages indexed so far along with ctx.Err(). Packages that fail to index fail
+// the module, unless opts.KeepGoing is set: the root manifest of the other packages is then returned along
+// with the failures.
+func indexModule(ctx context.Context, pkgPath, basePkgUrl, destFolder, sourceRoot string, opts Options, progressCallback func(ProgressInfo)) (*Manifest, error) {
+	// Packages whose sources are unchanged since the run recorded in destFolder are skipped
+	root := opts.Root
+	if root == "" {
+		root = "."
+	}
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	previous, stateErr := loadIndexState(destFolder)
+	if stateErr != nil && progressCallback != nil {
+		progressCallback(ProgressInfo{Warnings: []string{stateErr.Error()}})
+	}
+	run := newIncrementalRun(absRoot, destFolder, basePkgUrl, stateKey(pkgPath), previous, opts)
+	opts.skipPackage = run.skip
+	opts.packageFailed = run.failed
+
+	// Manifests of every package, collected for the root manifest, and the packages that failed to be written.
+	// The callback runs concurrently on the write workers.
+	var manifests []*Manifest
+	var writeErrs []error
+	var mu sync.Mutex
+
 	// Define the callback function that will be called for each package
 	callback := func(pkgInfo *PackageInfo, pkgUrl string) {
-		// Extract the relative package path from the full package URL
-		relativePkgPath := strings.TrimPrefix(pkgUrl, basePkgUrl)
-		relativePkgPath = strings.TrimPrefix(relativePkgPath, "/")
+		manifest, err := writePackageIndex(pkgInfo, pkgUrl, basePkgUrl, destFolder, sourceRoot, opts)
+		if err != nil {
+			// The package is written again by the next run
+			mu.Lock()
+			writeErrs = append(writeErrs, err)
+			mu.Unlock()
+			if rel, relErr := filepath.Rel(absRoot, pkgInfo.Dir); pkgInfo.Dir != "" && relErr == nil {
+				run.failed(rel)
+			}
+			return
+		}
+		if manifest == nil {
+			return
+		}
+		run.indexed(pkgInfo.Dir, manifest.Path)
+		mu.Lock()
+		manifests = append(manifests, manifest)
+		mu.Unlock()
+	}
+
+	// Scan the packages, writing their index files on the write workers as they are extracted
+	scanErr := scanPipeline(ctx, pkgPath, basePkgUrl, opts, opts.WriteWorkers, callback, progressCallback)
+	failures := errors.Join(append([]error{scanErr}, writeErrs...)...)
+	if failures != nil && ctx.Err() == nil && !opts.KeepGoing {
+		return nil, failures
+	}
+
+	// Skipped packages keep their index files and manifests from the previous run
+	manifests = append(manifests, run.skippedManifests()...)
+
+	// A cancelled run, or one with failures, records what it indexed, so the next run picks up from there.
+	// Index files of the packages that were not indexed are left alone.
+	if failures != nil {
+		if err := run.save(); err != nil {
+			return nil, errors.Join(failures, err)
+		}
+		return newModuleManifest(manifests, opts), failures
+	}
+
+	// Index files no manifest lists belong to deleted or renamed symbols
+	pruned, err := pruneStaleIndexFiles(destFolder, stateKey(pkgPath), manifests, opts.DryRun)
+	if err != nil {
+		return nil, err
+	}
+	if len(pruned) > 0 && progressCallback != nil {
+		progressCallback(ProgressInfo{Current: basePkgUrl, Pruned: pruned})
+	}
+	if err := run.save(); err != nil {
+		return nil, err
+	}
 
-		// Create the destination directory for this package
-		pkgDestDir := filepath.Join(destFolder, relativePkgPath)
+	return newModuleManifest(manifests, opts), nil
+}
 
-		// Process all indexable symbols in this package
-		saveIndexes(pkgDestDir, pkgInfo.Constants)
-		saveIndexes(pkgDestDir, pkgInfo.Variables)
-		saveIndexes(pkgDestDir, pkgInfo.Types)
-		saveIndexes(pkgDestDir, pkgInfo.Functions)
+// newModuleManifest builds the root manifest of a module from its package manifests, or returns nil
+// when no package was indexed
+func newModuleManifest(manifests []*Manifest, opts Options) *Manifest {
+	if len(manifests) == 0 {
+		return nil
 	}
+	rootManifest := newRootManifest(manifests)
+	rootManifest.Revision = opts.Revision
+	return rootManifest
+}
+
+// writePackageIndex writes the index files and manifest of a scanned package into its directory under
+// destFolder and returns the manifest, or nil when the package has no symbols. Files that cannot be written
+// are reported in a PackageError once the others are written.
+func writePackageIndex(pkgInfo *PackageInfo, pkgUrl, basePkgUrl, destFolder, sourceRoot string, opts Options) (*Manifest, error) {
+	// Extract the relative package path from the full package URL
+	relativePkgPath := strings.TrimPrefix(pkgUrl, basePkgUrl)
+	relativePkgPath = strings.TrimPrefix(relativePkgPath, "/")
+
+	// Create the destination directory for this package
+	pkgDestDir := filepath.Join(destFolder, relativePkgPath)
+
+	// Process all indexable symbols in this package
+	err := errors.Join(
+		saveIndexes(pkgDestDir, pkgInfo.Constants, opts.Revision),
+		saveIndexes(pkgDestDir, pkgInfo.Variables, opts.Revision),
+		saveIndexes(pkgDestDir, pkgInfo.Types, opts.Revision),
+		saveIndexes(pkgDestDir, pkgInfo.Functions, opts.Revision),
+	)
 
-	// Call ScanPackagesRecursively with our callback and progress callback
-	return ScanPackagesRecursively(pkgPath, basePkgUrl, callback, progressCallback)
+	// Packages without symbols produce no index files, so they get no manifest either
+	manifest := newPackageManifest(pkgInfo, pkgUrl, relativePkgPath, sourceRoot)
+	if len(manifest.Symbols) == 0 {
+		manifest = nil
+	} else {
+		manifest.Revision = opts.Revision
+		err = errors.Join(err, saveManifest(pkgDestDir, manifest))
+	}
+	if err != nil {
+		return nil, newPackageError(pkgUrl, PhaseWrite, err)
+	}
+	return manifest, nil
 }
 
 // IndexSourceCodeWithoutProgress provides backward compatibility for the old function signature
@@ -47,8 +298,10 @@ func IndexSourceCodeWithoutProgress(pkgPath, basePkgUrl string, destFolder strin
 	return IndexSourceCode(pkgPath, basePkgUrl, destFolder, nil)
 }
 
-// saveConstants creates index files for all constants in the package
-func saveIndexes[T IndexableSymbol](pkgDestDir string, indexes []T) {
+// saveIndexes creates index files for the symbols of a package, recording revision when it is not empty.
+// A file that cannot be written does not stop the others; the returned error joins every failure.
+func saveIndexes[T IndexableSymbol](pkgDestDir string, indexes []T, revision string) error {
+	var errs []error
 	for _, index := range indexes {
 		// Get the index filename using the IndexableSymbol interface
 		filename := index.IndexFileName()
@@ -59,26 +312,78 @@ func saveIndexes[T IndexableSymbol](pkgDestDir string, indexes []T) {
 		// Ensure the directory exists
 		dir := filepath.Dir(filePath)
 		if err := destFs.MkdirAll(dir, 0700); err != nil {
-			// Log error but continue processing other files
-			fmt.Printf("Warning: Failed to create directory %s: %v\n", dir, err)
-			return
+			// Continue with the other files
+			errs = append(errs, fmt.Errorf("failed to create directory %s: %w", dir, err))
+			continue
 		}
 
 		// Generate the index file content
-		content := generateIndexContent(index)
+		content := generateIndexContent(index, revision)
 
 		// Write the index file
 		if err := afero.WriteFile(destFs, filePath, []byte(content), 0600); err != nil {
-			// Log error but continue processing other files
-			fmt.Printf("Warning: Failed to write index file %s: %v\n", filePath, err)
+			// Continue with the other files
+			errs = append(errs, fmt.Errorf("failed to write index file %s: %w", filePath, err))
 		}
 	}
+	return errors.Join(errs...)
+}
+
+// indexImportPathPrefix starts the header comment recording the import path of an index file's package
+const indexImportPathPrefix = "// Import path: "
+
+// indexRevisionPrefix starts the header comment recording the commit an index file was generated from
+const indexRevisionPrefix = "// Revision: "
+
+// generateIndexContent generates the content for an index file.
+// The package clause carries the real package name while the import path, and the source revision
+// when known, go into header comments, so every index file is valid Go source.
+func generateIndexContent(symbol IndexableSymbol, revision string) string {
+	var sb strings.Builder
+	sb.WriteString("// Code generated by gophon. DO NOT EDIT.\n")
+	fmt.Fprintf(&sb, "%s%s\n", indexImportPathPrefix, symbol.PackagePath())
+	if revision != "" {
+		fmt.Fprintf(&sb, "%s%s\n", indexRevisionPrefix, revision)
+	}
+	sb.WriteString("\n")
+	fmt.Fprintf(&sb, "package %s\n\n", symbol.PackageName())
+	if imports := symbol.Imports(); imports != "" {
+		sb.WriteString(imports)
+		sb.WriteString("\n\n")
+	}
+	sb.WriteString(declarationSource(symbol))
+	sb.WriteString("\n")
+	if trailer := indexTrailer(symbol); trailer != "" {
+		sb.WriteString("\n")
+		sb.WriteString(trailer)
+	}
+	return sb.String()
 }
 
-// generateIndexContent generates the content for an index file
-func generateIndexContent(symbol IndexableSymbol) string {
-	return fmt.Sprintf(`package %s
-%s
-%s
-`, symbol.PackagePath(), symbol.Imports(), symbol.String())
+// indexTrailer returns the comment section appended after a symbol's source:
+// the method set of a type, or the computed value of a constant from an enum-style group
+func indexTrailer(symbol IndexableSymbol) string {
+	switch s := symbol.(type) {
+	case *TypeInfo:
+		return s.MethodSet()
+	case *ConstantInfo:
+		return s.ValueComment()
+	default:
+		return ""
+	}
+}
+
+// declarationSource returns the source of a symbol. A spec taken out of a parenthesized
+// const, var or type declaration is wrapped in its declaration keyword so it parses on its own.
+func declarationSource(symbol IndexableSymbol) string {
+	source := symbol.String()
+	grouped, ok := symbol.(groupedSymbol)
+	if !ok {
+		return source
+	}
+	genDecl, rangeInfo := grouped.enclosingDecl()
+	if genDecl == nil || rangeInfo == nil || !genDecl.Lparen.IsValid() || rangeInfo.covers(genDecl) {
+		return source
+	}
+	return fmt.Sprintf("%s (\n%s\n)", genDecl.Tok, source)
 }
@@ -1,9 +1,16 @@
 package pkg
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	"io/fs"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/prashantv/gostub"
 	"github.com/spf13/afero"
@@ -63,7 +70,7 @@ func TestIndexSourceCode_EmptyPackage(t *testing.T) {
 	defer stubs.Reset()
 
 	// Mock ScanPackagesRecursively to return empty package
-	stubs.Stub(&ScanPackage, func(pkgPath, basePkgUrl string) (*PackageInfo, error) {
+	stubs.Stub(&scanPackage, func(ctx context.Context, pkgPath, basePkgUrl string, opts Options) (*PackageInfo, error) {
 		return &PackageInfo{
 			Files:     []*FileInfo{},
 			Constants: []*ConstantInfo{},
@@ -82,3 +89,179 @@ func TestIndexSourceCode_EmptyPackage(t *testing.T) {
 	require.NoError(t, err)
 	assert.False(t, exists)
 }
+
+func TestIndexSourceCode_WritesManifests(t *testing.T) {
+	stub := gostub.Stub(&destFs, afero.NewMemMapFs())
+	defer stub.Reset()
+
+	require.NoError(t, IndexSourceCodeWithoutProgress("testharness", "github.com/lonegunmanb/gophon/pkg", "output"))
+
+	var pkgManifest Manifest
+	content, err := afero.ReadFile(destFs, "output/testharness/manifest.json")
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(content, &pkgManifest))
+
+	assert.Equal(t, "github.com/lonegunmanb/gophon/pkg/testharness", pkgManifest.Package)
+	assert.Equal(t, "testharness", pkgManifest.Path)
+	assert.Empty(t, pkgManifest.Packages, "Package manifests should not list other packages")
+
+	symbols := make(map[string]ManifestSymbol)
+	for _, symbol := range pkgManifest.Symbols {
+		symbols[symbol.IndexFile] = symbol
+	}
+
+	createUser, ok := symbols["testharness/method.Service.CreateUser.goindex"]
+	require.True(t, ok, "Manifest should list CreateUser method")
+	assert.Equal(t, "method", createUser.Kind)
+	assert.Equal(t, "CreateUser", createUser.Name)
+	assert.Equal(t, "*Service", createUser.Receiver)
+	assert.Equal(t, "testharness/subjects.go", createUser.SourceFile)
+	assert.Greater(t, createUser.EndLine, createUser.StartLine)
+	assert.True(t, createUser.Exported)
+
+	maxRetries, ok := symbols["testharness/var.maxRetries.goindex"]
+	require.True(t, ok, "Manifest should list maxRetries constant")
+	assert.Equal(t, "const", maxRetries.Kind)
+	assert.False(t, maxRetries.Exported)
+
+	// Every listed index file must exist
+	for indexFile := range symbols {
+		exists, err := afero.Exists(destFs, filepath.Join("output", indexFile))
+		require.NoError(t, err)
+		assert.True(t, exists, "Index file listed in manifest should exist: %s", indexFile)
+	}
+
+	var rootManifest Manifest
+	content, err = afero.ReadFile(destFs, "output/manifest.json")
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(content, &rootManifest))
+
+	var packagePaths []string
+	for _, manifest := range rootManifest.Packages {
+		packagePaths = append(packagePaths, manifest.Path)
+	}
+	assert.Contains(t, packagePaths, "testharness")
+	assert.Contains(t, packagePaths, "testharness/sub_pkg")
+	assert.Contains(t, rootManifest.Packages, ManifestPackage{
+		Package:  "github.com/lonegunmanb/gophon/pkg/testharness",
+		Path:     "testharness",
+		Manifest: "testharness/manifest.json",
+	}, "The root manifest links package manifests instead of repeating their symbols")
+	assert.NotContains(t, string(content), "CreateUser")
+}
+
+func TestIndexSourceCodeContext_CancelledRunKeepsIndexFiles(t *testing.T) {
+	srcFs := afero.NewMemMapFs()
+	stubs := gostub.Stub(&destFs, afero.NewMemMapFs())
+	stubs.Stub(&sourceFs, srcFs)
+	defer stubs.Reset()
+
+	require.NoError(t, afero.WriteFile(srcFs, "/src/go.mod", []byte("module example.com/cancel\n"), 0644))
+	require.NoError(t, afero.WriteFile(srcFs, "/src/a/a.go", []byte("package a\n\nfunc A() {}\n"), 0644))
+	require.NoError(t, afero.WriteFile(srcFs, "/src/b/b.go", []byte("package b\n\nfunc B() {}\n"), 0644))
+	opts := Options{Root: "/src"}
+	require.NoError(t, IndexSourceCodeWithOptions("", "", "output", opts, nil))
+
+	// Package a is gone and package b is interrupted while it loads
+	require.NoError(t, srcFs.RemoveAll("/src/a"))
+	require.NoError(t, afero.WriteFile(srcFs, "/src/b/b.go", []byte("package b\n\nfunc B2() {}\n"), 0644))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stubs.Stub(&scanPackage, func(ctx context.Context, pkgPath, basePkgUrl string, opts Options) (*PackageInfo, error) {
+		if pkgPath == "b" {
+			cancel()
+			return nil, ctx.Err()
+		}
+		return ScanSinglePackageContext(ctx, pkgPath, basePkgUrl, opts)
+	})
+
+	err := IndexSourceCodeContext(ctx, "", "", "output", opts, nil)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// Nothing is pruned, since the cancelled run did not see every package
+	for _, indexFile := range []string{"output/a/func.A.goindex", "output/b/func.B.goindex", "output/manifest.json"} {
+		exists, err := afero.Exists(destFs, indexFile)
+		require.NoError(t, err)
+		assert.True(t, exists, indexFile)
+	}
+	exists, err := afero.Exists(destFs, "output/b/func.B2.goindex")
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestIndexSourceCode_SyntaxOnlyMatchesFullLoad(t *testing.T) {
+	fullFs := afero.NewMemMapFs()
+	syntaxFs := afero.NewMemMapFs()
+	stubs := gostub.Stub(&destFs, fullFs)
+	defer stubs.Reset()
+	// This package's own code passes function literals to imported functions, which are left unchecked without imports
+	require.NoError(t, IndexSourceCodeWithOptions("", "github.com/lonegunmanb/gophon/pkg", "output", Options{}, nil))
+
+	// Without the go command on the PATH, only a syntax-only run can load packages
+	t.Setenv("PATH", t.TempDir())
+	stubs.Stub(&destFs, syntaxFs)
+	require.NoError(t, IndexSourceCodeWithOptions("", "github.com/lonegunmanb/gophon/pkg", "output", Options{SyntaxOnly: true}, nil))
+
+	var indexFiles int
+	require.NoError(t, afero.Walk(fullFs, "output", func(filePath string, info fs.FileInfo, err error) error {
+		// State files record the mode in their options fingerprint
+		if err != nil || info.IsDir() || info.Name() == stateFileName {
+			return err
+		}
+		expected, err := afero.ReadFile(fullFs, filePath)
+		require.NoError(t, err)
+		actual, err := afero.ReadFile(syntaxFs, filePath)
+		require.NoError(t, err, filePath)
+		assert.Equal(t, string(expected), string(actual), filePath)
+		if strings.HasSuffix(filePath, ".goindex") {
+			indexFiles++
+		}
+		return nil
+	}))
+	assert.NotZero(t, indexFiles)
+}
+
+// slowFs counts the files being opened for writing at the same time
+type slowFs struct {
+	afero.Fs
+	inFlight    atomic.Int32
+	maxInFlight atomic.Int32
+}
+
+func (f *slowFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	if flag&os.O_CREATE != 0 {
+		current := f.inFlight.Add(1)
+		defer f.inFlight.Add(-1)
+		for {
+			previous := f.maxInFlight.Load()
+			if current <= previous || f.maxInFlight.CompareAndSwap(previous, current) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return f.Fs.OpenFile(name, flag, perm)
+}
+
+func TestIndexSourceCode_WriteWorkers(t *testing.T) {
+	srcFs := afero.NewMemMapFs()
+	stubs := gostub.Stub(&sourceFs, srcFs)
+	defer stubs.Reset()
+	require.NoError(t, afero.WriteFile(srcFs, "/src/go.mod", []byte("module example.com/writes\n"), 0644))
+	for _, name := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+		content := fmt.Sprintf("package %s\n\nfunc One() {}\n\nfunc Two() {}\n", name)
+		require.NoError(t, afero.WriteFile(srcFs, "/src/"+name+"/"+name+".go", []byte(content), 0644))
+	}
+
+	maxConcurrentWrites := func(writeWorkers int) int32 {
+		dest := &slowFs{Fs: afero.NewMemMapFs()}
+		stubs.Stub(&destFs, dest)
+		require.NoError(t, IndexSourceCodeWithOptions("", "", "output", Options{Root: "/src", WriteWorkers: writeWorkers}, nil))
+		assertRootManifestPackages(t, "example.com/writes/a", "example.com/writes/b", "example.com/writes/c", "example.com/writes/d",
+			"example.com/writes/e", "example.com/writes/f", "example.com/writes/g", "example.com/writes/h")
+		return dest.maxInFlight.Load()
+	}
+
+	assert.Equal(t, int32(1), maxConcurrentWrites(1))
+	assert.Greater(t, maxConcurrentWrites(4), int32(1))
+}
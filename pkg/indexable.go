@@ -1,5 +1,7 @@
 package pkg
 
+import "go/ast"
+
 // IndexableSymbol represents a Go symbol that can generate a predictable index file name
 // for AI agents to easily guess and access when reading Go source code.
 type IndexableSymbol interface {
@@ -14,5 +16,12 @@ type IndexableSymbol interface {
 	IndexFileName() string
 	String() string
 	PackagePath() string
+	PackageName() string
 	Imports() string
 }
+
+// groupedSymbol is implemented by symbols declared by a spec inside a const, var or type declaration
+type groupedSymbol interface {
+	IndexableSymbol
+	enclosingDecl() (*ast.GenDecl, *Range)
+}
@@ -0,0 +1,119 @@
+package pkg
+
+import (
+	"errors"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"go/types"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"sync"
+
+	"github.com/spf13/afero"
+	"golang.org/x/tools/go/packages"
+)
+
+// sourceFsMu serializes indexing runs that replace sourceFs
+var sourceFsMu sync.Mutex
+
+// withSourceFs runs fn with sourceFs replaced by fs, restoring it afterwards
+func withSourceFs(fs afero.Fs, fn func() error) error {
+	sourceFsMu.Lock()
+	defer sourceFsMu.Unlock()
+	previous := sourceFs
+	sourceFs = fs
+	defer func() { sourceFs = previous }()
+	return fn()
+}
+
+// isOsFs reports whether fs is the operating system's filesystem
+func isOsFs(fs afero.Fs) bool {
+	_, ok := fs.(*afero.OsFs)
+	return ok
+}
+
+// loadPackageFromFs parses and type-checks the package in dir from sourceFs without the go command.
+// Files are selected by the build constraints of the default build context. Imported packages are
+// not loaded; they are stubbed and the resulting type errors are tolerated, so the package's own
+// declarations, constant values and import references are still resolved.
+func loadPackageFromFs(dir, importPath string) (*packages.Package, error) {
+	ctxt := build.Default
+	ctxt.JoinPath = filepath.Join
+	ctxt.IsDir = func(path string) bool {
+		info, err := sourceFs.Stat(path)
+		return err == nil && info.IsDir()
+	}
+	ctxt.ReadDir = func(dir string) ([]fs.FileInfo, error) {
+		return afero.ReadDir(sourceFs, dir)
+	}
+	ctxt.OpenFile = func(path string) (io.ReadCloser, error) {
+		return sourceFs.Open(path)
+	}
+
+	pkg := &packages.Package{ID: importPath, PkgPath: importPath, Dir: dir}
+	buildPkg, err := ctxt.ImportDir(dir, 0)
+	if err != nil {
+		var noGoErr *build.NoGoError
+		if errors.As(err, &noGoErr) {
+			// Directories without Go files are empty packages
+			return pkg, nil
+		}
+		return nil, err
+	}
+	pkg.Name = buildPkg.Name
+
+	pkg.Fset = token.NewFileSet()
+	for _, name := range append(buildPkg.GoFiles, buildPkg.CgoFiles...) {
+		fileName := filepath.Join(dir, name)
+		content, err := afero.ReadFile(sourceFs, fileName)
+		if err != nil {
+			return nil, err
+		}
+		file, err := parser.ParseFile(pkg.Fset, fileName, content, parser.ParseComments)
+		if file == nil {
+			return nil, err
+		}
+		// The declarations that parsed are kept, and the syntax errors reported
+		var syntaxErrs scanner.ErrorList
+		if errors.As(err, &syntaxErrs) {
+			for _, syntaxErr := range syntaxErrs {
+				pkg.Errors = append(pkg.Errors, packages.Error{Pos: syntaxErr.Pos.String(), Msg: syntaxErr.Msg, Kind: packages.ParseError})
+			}
+		}
+		pkg.GoFiles = append(pkg.GoFiles, fileName)
+		pkg.CompiledGoFiles = append(pkg.CompiledGoFiles, fileName)
+		pkg.Syntax = append(pkg.Syntax, file)
+	}
+
+	pkg.TypesInfo = &types.Info{
+		Types:      make(map[ast.Expr]types.TypeAndValue),
+		Defs:       make(map[*ast.Ident]types.Object),
+		Uses:       make(map[*ast.Ident]types.Object),
+		Implicits:  make(map[ast.Node]types.Object),
+		Selections: make(map[*ast.SelectorExpr]*types.Selection),
+		Scopes:     make(map[ast.Node]*types.Scope),
+	}
+	conf := types.Config{
+		Importer:    stubImporter{},
+		FakeImportC: true,
+		Error:       func(error) {}, // Unresolved imports leave type errors behind
+	}
+	pkg.Types, _ = conf.Check(importPath, pkg.Fset, pkg.Syntax, pkg.TypesInfo)
+	return pkg, nil
+}
+
+// stubImporter imports every package as an empty, complete package named after its import path
+type stubImporter struct{}
+
+func (stubImporter) Import(path string) (*types.Package, error) {
+	if path == "unsafe" {
+		return types.Unsafe, nil
+	}
+	pkg := types.NewPackage(path, assumedPackageName(path))
+	pkg.MarkComplete()
+	return pkg, nil
+}
@@ -0,0 +1,243 @@
+package pkg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/ast"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"github.com/spf13/afero"
+)
+
+// manifestFileName is the name of the manifest written alongside index files
+const manifestFileName = "manifest.json"
+
+// ManifestSymbol describes a single indexed symbol
+type ManifestSymbol struct {
+	Kind       string   `json:"kind"`                 // const, var, type, func or method
+	Name       string   `json:"name"`                 // Symbol name
+	Receiver   string   `json:"receiver,omitempty"`   // Receiver type for methods, e.g. *Service
+	TypeParams []string `json:"typeParams,omitempty"` // Type parameters of generic types and of generic method receivers
+	IndexFile  string   `json:"indexFile"`            // Index file path relative to the destination root
+	SourceFile string   `json:"sourceFile"`           // Source file path relative to the scanned source root
+	StartLine  int      `json:"startLine"`            // 1-based line number (inclusive)
+	EndLine    int      `json:"endLine"`              // 1-based line number (inclusive)
+	Exported   bool     `json:"exported"`             // Whether the symbol is exported
+}
+
+// Manifest lists the symbols indexed for a package so agents and tooling can discover them
+// without walking the destination directory. The manifest at the destination root additionally
+// links the manifest of every package indexed in the run; in a workspace, paths are relative to the
+// module's directory.
+type Manifest struct {
+	Package  string           `json:"package,omitempty"`  // Full package URL
+	Path     string           `json:"path"`               // Package directory relative to the destination root
+	Revision string           `json:"revision,omitempty"` // Commit the index was generated from, set when indexing a git revision
+	Symbols  []ManifestSymbol `json:"symbols,omitempty"`  // Symbols declared in the package
+	// Collisions lists index file names shared by several symbols of the package and the files written instead
+	Collisions []IndexFileCollision `json:"collisions,omitempty"`
+	Packages   []ManifestPackage    `json:"packages,omitempty"` // Every indexed package, only set in the root manifest
+	Modules    []ManifestModule     `json:"modules,omitempty"`  // Every indexed module, only set in the root manifest
+}
+
+// ManifestPackage links the manifest of a package from the root manifest
+type ManifestPackage struct {
+	Package  string `json:"package"`  // Full package URL
+	Path     string `json:"path"`     // Package directory relative to the destination root
+	Manifest string `json:"manifest"` // The package's manifest relative to the destination root
+}
+
+// ManifestModule links a module of a workspace or an indexed dependency from the root manifest
+type ManifestModule struct {
+	Path     string `json:"path"`              // Module path declared in go.mod
+	Version  string `json:"version,omitempty"` // Module version, set for dependencies
+	Dir      string `json:"dir"`               // Module directory relative to the destination root
+	Manifest string `json:"manifest"`          // The module's manifest relative to the destination root
+}
+
+// newPackageManifest builds the manifest of a single package
+func newPackageManifest(pkgInfo *PackageInfo, pkgUrl, relativePkgPath, sourceRoot string) *Manifest {
+	manifest := &Manifest{
+		Package:    pkgUrl,
+		Path:       path.Clean("./" + filepath.ToSlash(relativePkgPath)),
+		Collisions: pkgInfo.Collisions,
+	}
+
+	add := func(kind, name, receiver string, typeParams []string, symbol IndexableSymbol, rangeInfo *Range) {
+		manifest.Symbols = append(manifest.Symbols, ManifestSymbol{
+			Kind:       kind,
+			Name:       name,
+			Receiver:   receiver,
+			TypeParams: typeParams,
+			IndexFile:  path.Join(manifest.Path, symbol.IndexFileName()),
+			SourceFile: relativeSourceFile(sourceRoot, rangeInfo.FileName),
+			StartLine:  rangeInfo.StartLine,
+			EndLine:    rangeInfo.EndLine,
+			Exported:   ast.IsExported(name),
+		})
+	}
+
+	for _, c := range pkgInfo.Constants {
+		add("const", c.Name, "", nil, c, c.Range)
+	}
+	for _, v := range pkgInfo.Variables {
+		add("var", v.Name, "", nil, v, v.Range)
+	}
+	for _, t := range pkgInfo.Types {
+		add("type", t.Name, "", t.TypeParams(), t, t.Range)
+	}
+	for _, f := range pkgInfo.Functions {
+		kind := "func"
+		if f.ReceiverType != "" {
+			kind = "method"
+		}
+		add(kind, f.Name, f.ReceiverType, f.ReceiverTypeParams, f, f.Range)
+	}
+
+	sort.Slice(manifest.Symbols, func(i, j int) bool {
+		return manifest.Symbols[i].IndexFile < manifest.Symbols[j].IndexFile
+	})
+	return manifest
+}
+
+// newRootManifest builds the manifest written at the destination root, linking the manifest of every package.
+// Symbols stay in the package manifests, so the root manifest does not grow with them.
+func newRootManifest(packages []*Manifest) *Manifest {
+	root := &Manifest{Path: "."}
+	for _, manifest := range packages {
+		root.Packages = append(root.Packages, ManifestPackage{
+			Package:  manifest.Package,
+			Path:     manifest.Path,
+			Manifest: path.Join(manifest.Path, manifestFileName),
+		})
+
+		// The root package shares its directory with the root manifest, so its symbols live here
+		if manifest.Path == "." {
+			root.Package = manifest.Package
+			root.Symbols = manifest.Symbols
+			root.Collisions = manifest.Collisions
+		}
+	}
+	sort.Slice(root.Packages, func(i, j int) bool {
+		return root.Packages[i].Path < root.Packages[j].Path
+	})
+	return root
+}
+
+// linkModules adds module links to the root manifest of destFolder, keeping the manifest's other content
+// and replacing links to the same directories
+func linkModules(destFolder string, links []ManifestModule) error {
+	root := &Manifest{Path: "."}
+	if content, err := afero.ReadFile(destFs, filepath.Join(destFolder, manifestFileName)); err == nil {
+		if err := json.Unmarshal(content, root); err != nil {
+			return fmt.Errorf("failed to decode manifest in %s: %w", destFolder, err)
+		}
+	}
+
+	linked := make(map[string]bool)
+	for _, link := range links {
+		linked[link.Dir] = true
+	}
+	modules := links
+	for _, module := range root.Modules {
+		if !linked[module.Dir] {
+			modules = append(modules, module)
+		}
+	}
+	sort.Slice(modules, func(i, j int) bool {
+		return modules[i].Dir < modules[j].Dir
+	})
+	root.Modules = modules
+	return saveManifest(destFolder, root)
+}
+
+// loadRootManifest reads the root manifest of destFolder, or returns an empty one when there is none
+func loadRootManifest(destFolder string) (*Manifest, error) {
+	root := &Manifest{Path: "."}
+	content, err := afero.ReadFile(destFs, filepath.Join(destFolder, manifestFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return root, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(content, root); err != nil {
+		return nil, fmt.Errorf("failed to decode manifest in %s: %w", destFolder, err)
+	}
+	return root, nil
+}
+
+// saveRootManifest writes the root manifest of a run over the subtree index directory of destFolder.
+// A run over the whole destination, ".", replaces the root manifest; a subtree run replaces the links
+// to the packages in its subtree and keeps the others.
+func saveRootManifest(destFolder, subtree string, manifest *Manifest) error {
+	if subtree == "." {
+		if manifest == nil {
+			return nil
+		}
+		return saveManifest(destFolder, manifest)
+	}
+
+	previous, err := loadRootManifest(destFolder)
+	if err != nil {
+		return err
+	}
+	root := manifest
+	if root == nil {
+		root = &Manifest{Path: ".", Revision: previous.Revision}
+	}
+	for _, link := range previous.Packages {
+		if withinSubtree(link.Path, subtree) {
+			continue
+		}
+		root.Packages = append(root.Packages, link)
+		if link.Path == "." {
+			root.Package = previous.Package
+			root.Symbols = previous.Symbols
+			root.Collisions = previous.Collisions
+		}
+	}
+	sort.Slice(root.Packages, func(i, j int) bool {
+		return root.Packages[i].Path < root.Packages[j].Path
+	})
+	if root.Modules == nil {
+		root.Modules = previous.Modules
+	}
+	if len(root.Packages) == 0 && len(root.Modules) == 0 {
+		return nil
+	}
+	return saveManifest(destFolder, root)
+}
+
+// saveManifest writes a manifest as indented JSON into dir
+func saveManifest(dir string, manifest *Manifest) error {
+	content, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest for %s: %w", dir, err)
+	}
+
+	if err := destFs.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", dir, err)
+	}
+
+	filePath := filepath.Join(dir, manifestFileName)
+	if err := afero.WriteFile(destFs, filePath, append(content, '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to write manifest %s: %w", filePath, err)
+	}
+	return nil
+}
+
+// relativeSourceFile returns fileName relative to sourceRoot using forward slashes,
+// falling back to the original path when it lies outside the root
+func relativeSourceFile(sourceRoot, fileName string) string {
+	if sourceRoot != "" {
+		if rel, err := filepath.Rel(sourceRoot, fileName); err == nil && filepath.IsLocal(rel) {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return filepath.ToSlash(fileName)
+}
@@ -0,0 +1,221 @@
+package pkg
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// mcpProtocolVersion is the Model Context Protocol revision implemented by ServeMCP
+const mcpProtocolVersion = "2024-11-05"
+
+// maxSearchResults caps the number of symbols returned by the search_symbols tool
+const maxSearchResults = 100
+
+// JSON-RPC 2.0 error codes used by the MCP server
+const (
+	jsonRPCParseError     = -32700
+	jsonRPCMethodNotFound = -32601
+	jsonRPCInvalidParams  = -32602
+)
+
+type jsonRPCRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type jsonRPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type jsonRPCResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *jsonRPCError   `json:"error,omitempty"`
+}
+
+type mcpTool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	InputSchema map[string]any `json:"inputSchema"`
+}
+
+type mcpContent struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type mcpToolResult struct {
+	Content []mcpContent `json:"content"`
+	IsError bool         `json:"isError,omitempty"`
+}
+
+// mcpTools describes the tools exposed by the MCP server
+var mcpTools = []mcpTool{
+	{
+		Name:        "get_symbol",
+		Description: "Return the index file content (package clause, imports and exact source) of a Go symbol; every match is returned when several symbols share the name, such as init functions.",
+		InputSchema: objectSchema(map[string]string{
+			"package": "Full package URL or path relative to the base package URL",
+			"kind":    "Symbol kind: func, method, type, var or const",
+			"name":    "Symbol name; for methods use <ReceiverType>.<MethodName>",
+		}, "package", "kind", "name"),
+	},
+	{
+		Name:        "list_package",
+		Description: "List the index files of every symbol declared in a Go package.",
+		InputSchema: objectSchema(map[string]string{
+			"package": "Full package URL or path relative to the base package URL",
+		}, "package"),
+	},
+	{
+		Name:        "search_symbols",
+		Description: "Search symbol names across all indexed packages (case-insensitive substring match).",
+		InputSchema: objectSchema(map[string]string{
+			"query": "Substring to look for in symbol names",
+		}, "query"),
+	},
+}
+
+// objectSchema builds a JSON schema for an object whose properties are all strings
+func objectSchema(properties map[string]string, required ...string) map[string]any {
+	props := make(map[string]any, len(properties))
+	for name, description := range properties {
+		props[name] = map[string]string{
+			"type":        "string",
+			"description": description,
+		}
+	}
+	return map[string]any{
+		"type":       "object",
+		"properties": props,
+		"required":   required,
+	}
+}
+
+// ServeMCP speaks the Model Context Protocol over newline-delimited JSON-RPC messages read from r,
+// writing responses to w. It answers get_symbol, list_package and search_symbols tool calls from
+// the given SymbolStore and returns when r reaches EOF.
+func ServeMCP(store *SymbolStore, r io.Reader, w io.Writer) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	encoder := json.NewEncoder(w)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var request jsonRPCRequest
+		if err := json.Unmarshal([]byte(line), &request); err != nil {
+			response := jsonRPCResponse{
+				JSONRPC: "2.0",
+				ID:      json.RawMessage("null"),
+				Error:   &jsonRPCError{Code: jsonRPCParseError, Message: err.Error()},
+			}
+			if err := encoder.Encode(response); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// Notifications carry no id and must not be answered
+		if len(request.ID) == 0 {
+			continue
+		}
+
+		response := handleMCPRequest(store, request)
+		if err := encoder.Encode(response); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
+
+// handleMCPRequest dispatches a single JSON-RPC request to the matching MCP method
+func handleMCPRequest(store *SymbolStore, request jsonRPCRequest) jsonRPCResponse {
+	response := jsonRPCResponse{
+		JSONRPC: "2.0",
+		ID:      request.ID,
+	}
+
+	switch request.Method {
+	case "initialize":
+		response.Result = map[string]any{
+			"protocolVersion": mcpProtocolVersion,
+			"capabilities": map[string]any{
+				"tools": map[string]any{},
+			},
+			"serverInfo": map[string]string{
+				"name":    "gophon",
+				"version": "0.1.0",
+			},
+		}
+	case "ping":
+		response.Result = map[string]any{}
+	case "tools/list":
+		response.Result = map[string]any{
+			"tools": mcpTools,
+		}
+	case "tools/call":
+		var params struct {
+			Name      string            `json:"name"`
+			Arguments map[string]string `json:"arguments"`
+		}
+		if err := json.Unmarshal(request.Params, &params); err != nil {
+			response.Error = &jsonRPCError{Code: jsonRPCInvalidParams, Message: err.Error()}
+			return response
+		}
+		response.Result = callMCPTool(store, params.Name, params.Arguments)
+	default:
+		response.Error = &jsonRPCError{Code: jsonRPCMethodNotFound, Message: fmt.Sprintf("method %s not found", request.Method)}
+	}
+
+	return response
+}
+
+// callMCPTool runs a tool against the store; tool failures are reported in the result, not as JSON-RPC errors
+func callMCPTool(store *SymbolStore, name string, args map[string]string) mcpToolResult {
+	text, err := func() (string, error) {
+		switch name {
+		case "get_symbol":
+			return store.GetSymbol(args["package"], args["kind"], args["name"])
+		case "list_package":
+			indexFiles, err := store.ListPackage(args["package"])
+			if err != nil {
+				return "", err
+			}
+			return strings.Join(indexFiles, "\n"), nil
+		case "search_symbols":
+			matches := store.Search(args["query"])
+			if len(matches) > maxSearchResults {
+				matches = matches[:maxSearchResults]
+			}
+			var lines []string
+			for _, match := range matches {
+				lines = append(lines, fmt.Sprintf("%s %s", match.Package, match.IndexFile))
+			}
+			return strings.Join(lines, "\n"), nil
+		default:
+			return "", fmt.Errorf("unknown tool %s", name)
+		}
+	}()
+
+	if err != nil {
+		return mcpToolResult{
+			Content: []mcpContent{{Type: "text", Text: err.Error()}},
+			IsError: true,
+		}
+	}
+	return mcpToolResult{
+		Content: []mcpContent{{Type: "text", Text: text}},
+	}
+}
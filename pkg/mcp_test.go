@@ -0,0 +1,128 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/prashantv/gostub"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServeMCP_ToolCalls(t *testing.T) {
+	store, err := BuildSymbolStore("testharness", "github.com/lonegunmanb/gophon/pkg", Options{}, nil)
+	require.NoError(t, err)
+
+	input := strings.Join([]string{
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2024-11-05","capabilities":{},"clientInfo":{"name":"test","version":"1.0"}}}`,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`,
+		`{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"get_symbol","arguments":{"package":"testharness","kind":"method","name":"*Service.CreateUser"}}}`,
+		`{"jsonrpc":"2.0","id":4,"method":"tools/call","params":{"name":"list_package","arguments":{"package":"github.com/lonegunmanb/gophon/pkg/testharness"}}}`,
+		`{"jsonrpc":"2.0","id":5,"method":"tools/call","params":{"name":"search_symbols","arguments":{"query":"user"}}}`,
+		`{"jsonrpc":"2.0","id":6,"method":"tools/call","params":{"name":"get_symbol","arguments":{"package":"testharness","kind":"func","name":"DoesNotExist"}}}`,
+		`{"jsonrpc":"2.0","id":7,"method":"unknown/method"}`,
+	}, "\n")
+
+	var output bytes.Buffer
+	require.NoError(t, ServeMCP(store, strings.NewReader(input), &output))
+
+	responses := decodeMCPResponses(t, output.String())
+	require.Len(t, responses, 7, "Notifications must not be answered")
+
+	assert.Equal(t, mcpProtocolVersion, responses[0].Result["protocolVersion"])
+
+	tools := responses[1].Result["tools"].([]any)
+	var toolNames []string
+	for _, tool := range tools {
+		toolNames = append(toolNames, tool.(map[string]any)["name"].(string))
+	}
+	assert.ElementsMatch(t, []string{"get_symbol", "list_package", "search_symbols"}, toolNames)
+
+	assert.Contains(t, mcpResultText(responses[2]), "func (s *Service) CreateUser(ctx context.Context, name, email string) (*User, error) {")
+	assert.Contains(t, mcpResultText(responses[3]), "type.User.goindex")
+	assert.Contains(t, mcpResultText(responses[3]), "method.Service.GetUser.goindex")
+	assert.Contains(t, mcpResultText(responses[4]), "github.com/lonegunmanb/gophon/pkg/testharness type.UserService.goindex")
+
+	assert.Equal(t, true, responses[5].Result["isError"])
+	assert.Contains(t, mcpResultText(responses[5]), "func.DoesNotExist.goindex")
+
+	require.NotNil(t, responses[6].Error)
+	assert.Equal(t, jsonRPCMethodNotFound, responses[6].Error.Code)
+}
+
+func TestLoadSymbolStore_ReadsIndexTree(t *testing.T) {
+	stub := gostub.Stub(&destFs, afero.NewMemMapFs())
+	defer stub.Reset()
+
+	require.NoError(t, IndexSourceCodeWithoutProgress("testharness", "github.com/lonegunmanb/gophon/pkg", "output"))
+
+	store, err := LoadSymbolStore("output", "github.com/lonegunmanb/gophon/pkg")
+	require.NoError(t, err)
+
+	assert.Contains(t, store.Packages(), "github.com/lonegunmanb/gophon/pkg/testharness")
+
+	content, err := store.GetSymbol("testharness", "type", "User")
+	require.NoError(t, err)
+	assert.Contains(t, content, "type User struct {")
+
+	content, err = store.GetSymbol("testharness", "const", "DefaultTimeout")
+	require.NoError(t, err)
+	assert.Contains(t, content, "DefaultTimeout = 30 * time.Second")
+
+	_, err = store.GetSymbol("testharness", "struct", "User")
+	assert.Error(t, err)
+}
+
+func TestSymbolStore_GetSymbolReturnsEveryDisambiguatedMatch(t *testing.T) {
+	stub := gostub.Stub(&destFs, afero.NewMemMapFs())
+	defer stub.Reset()
+
+	require.NoError(t, IndexSourceCodeWithoutProgress("testharness/collisions", "github.com/lonegunmanb/gophon/pkg", "output"))
+	loaded, err := LoadSymbolStore("output", "github.com/lonegunmanb/gophon/pkg")
+	require.NoError(t, err)
+	built, err := BuildSymbolStore("testharness/collisions", "github.com/lonegunmanb/gophon/pkg", Options{}, nil)
+	require.NoError(t, err)
+
+	for name, store := range map[string]*SymbolStore{"loaded": loaded, "built": built} {
+		t.Run(name, func(t *testing.T) {
+			content, err := store.GetSymbol("testharness/collisions", "func", "init")
+			require.NoError(t, err)
+			assert.Contains(t, content, "// Index file: func.init.goindex")
+			assert.Contains(t, content, "// Index file: func.init.1.goindex")
+			assert.Contains(t, content, `registry = append(registry, "a")`)
+			assert.Contains(t, content, `registry = append(registry, "b")`)
+
+			content, err = store.GetSymbol("testharness/collisions", "func", "init.1")
+			require.NoError(t, err)
+			assert.NotContains(t, content, "// Index file:")
+			assert.Contains(t, content, "func init() {")
+		})
+	}
+}
+
+type mcpTestResponse struct {
+	ID     int            `json:"id"`
+	Result map[string]any `json:"result"`
+	Error  *jsonRPCError  `json:"error"`
+}
+
+// decodeMCPResponses decodes newline-delimited JSON-RPC responses
+func decodeMCPResponses(t *testing.T, output string) []mcpTestResponse {
+	var responses []mcpTestResponse
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		var response mcpTestResponse
+		require.NoError(t, json.Unmarshal([]byte(line), &response))
+		responses = append(responses, response)
+	}
+	return responses
+}
+
+// mcpResultText returns the text of the first content item of a tool call result
+func mcpResultText(response mcpTestResponse) string {
+	content := response.Result["content"].([]any)
+	return content[0].(map[string]any)["text"].(string)
+}
@@ -0,0 +1,58 @@
+package pkg
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+
+	"github.com/spf13/afero"
+	"golang.org/x/mod/modfile"
+)
+
+// DetectBasePkgUrl returns the import path of the directory root, read from the go.mod of
+// the module containing it. The nearest go.mod in root or any of its parents is used, and the
+// directory's path relative to that go.mod is appended to the module path.
+func DetectBasePkgUrl(root string) (string, error) {
+	if root == "" {
+		root = "."
+	}
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return "", err
+	}
+
+	for dir := absRoot; ; dir = filepath.Dir(dir) {
+		goModPath := filepath.Join(dir, "go.mod")
+		content, err := afero.ReadFile(sourceFs, goModPath)
+		if err == nil {
+			modulePath := modfile.ModulePath(content)
+			if modulePath == "" {
+				return "", fmt.Errorf("no module directive found in %s", goModPath)
+			}
+			rel, err := filepath.Rel(dir, absRoot)
+			if err != nil {
+				return "", err
+			}
+			if modulePath == stdModulePath {
+				// Standard library import paths carry no module prefix
+				return joinPackageUrl("", rel), nil
+			}
+			return path.Join(modulePath, filepath.ToSlash(rel)), nil
+		}
+		if filepath.Dir(dir) == dir {
+			return "", fmt.Errorf("no go.mod found in %s or any parent directory", absRoot)
+		}
+	}
+}
+
+// resolveBasePkgUrl returns basePkgUrl, or the base package URL detected from opts.Root when it is empty
+func resolveBasePkgUrl(basePkgUrl string, opts Options) (string, error) {
+	if basePkgUrl != "" {
+		return basePkgUrl, nil
+	}
+	detected, err := DetectBasePkgUrl(opts.Root)
+	if err != nil {
+		return "", fmt.Errorf("failed to detect base package URL: %w", err)
+	}
+	return detected, nil
+}
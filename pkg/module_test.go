@@ -0,0 +1,63 @@
+package pkg
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/prashantv/gostub"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectBasePkgUrl(t *testing.T) {
+	mockFs := afero.NewMemMapFs()
+	stub := gostub.Stub(&sourceFs, mockFs)
+	defer stub.Reset()
+
+	require.NoError(t, afero.WriteFile(mockFs, "/work/repo/go.mod", []byte("module example.com/repo\n\ngo 1.23\n"), 0644))
+	require.NoError(t, mockFs.MkdirAll("/work/repo/internal/store", 0755))
+	require.NoError(t, mockFs.MkdirAll("/elsewhere", 0755))
+
+	base, err := DetectBasePkgUrl("/work/repo")
+	require.NoError(t, err)
+	assert.Equal(t, "example.com/repo", base)
+
+	base, err = DetectBasePkgUrl("/work/repo/internal/store")
+	require.NoError(t, err)
+	assert.Equal(t, "example.com/repo/internal/store", base)
+
+	_, err = DetectBasePkgUrl("/elsewhere")
+	assert.Error(t, err)
+}
+
+func TestIndexSourceCode_RootWithoutBase(t *testing.T) {
+	stub := gostub.Stub(&destFs, afero.NewMemMapFs())
+	defer stub.Reset()
+
+	// Index the testharness from the module root as if gophon ran from another directory
+	opts := Options{Root: "../pkg/testharness"}
+	require.NoError(t, IndexSourceCodeWithOptions("", "", "output", opts, nil))
+
+	content, err := afero.ReadFile(destFs, "output/manifest.json")
+	require.NoError(t, err)
+	var manifest Manifest
+	require.NoError(t, json.Unmarshal(content, &manifest))
+	assert.Equal(t, "github.com/lonegunmanb/gophon/pkg/testharness", manifest.Package)
+	// Source files are recorded relative to the root, not the working directory
+	var userSourceFile string
+	for _, symbol := range manifest.Symbols {
+		if symbol.Kind == "type" && symbol.Name == "User" {
+			userSourceFile = symbol.SourceFile
+		}
+	}
+	assert.Equal(t, "subjects.go", userSourceFile)
+
+	exists, err := afero.Exists(destFs, "output/type.User.goindex")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	exists, err = afero.Exists(destFs, "output/generics/type.List.goindex")
+	require.NoError(t, err)
+	assert.True(t, exists)
+}
@@ -0,0 +1,126 @@
+package pkg
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/afero"
+	"golang.org/x/mod/module"
+)
+
+// ModuleZipFromProxy returns the path of the zip of modulePath at version in a GOPROXY directory,
+// given as a file:// URL or a plain path, following the GOPROXY protocol layout <module>/@v/<version>.zip
+func ModuleZipFromProxy(proxy, modulePath, version string) (string, error) {
+	proxyDir := proxy
+	if strings.HasPrefix(proxy, "file://") {
+		proxyUrl, err := url.Parse(proxy)
+		if err != nil {
+			return "", fmt.Errorf("invalid GOPROXY URL %s: %w", proxy, err)
+		}
+		proxyDir = filepath.FromSlash(proxyUrl.Path)
+	} else if strings.Contains(proxy, "://") {
+		return "", fmt.Errorf("only file:// GOPROXY directories are supported, got %s", proxy)
+	}
+
+	escapedPath, err := module.EscapePath(modulePath)
+	if err != nil {
+		return "", err
+	}
+	escapedVersion, err := module.EscapeVersion(version)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(proxyDir, filepath.FromSlash(escapedPath), "@v", escapedVersion+".zip"), nil
+}
+
+// IndexModuleZip indexes modulePath at version from a module zip in the `go mod download` format,
+// without extracting it to disk. The zip is unpacked into an in-memory filesystem that serves as
+// sourceFs while indexing, so packages are loaded without the go command and imports stay unresolved.
+// Index files go to a module@version subtree of destFolder, linked from its root manifest, and package
+// failures and cancellation through ctx are handled as by IndexSourceCodeContext, with failures reported
+// in the errors.json of the subtree.
+func IndexModuleZip(ctx context.Context, zipPath, modulePath, version, destFolder string, opts Options, progressCallback func(ProgressInfo)) error {
+	modDir := modulePath + "@" + version
+	memFs := afero.NewMemMapFs()
+	memRoot := "/" + modDir
+	if err := unzipModule(zipPath, modulePath, version, memFs, memRoot); err != nil {
+		return err
+	}
+
+	zipOpts := opts
+	zipOpts.Root = memRoot
+	modDestFolder := filepath.Join(destFolder, filepath.FromSlash(modDir))
+	var manifest *Manifest
+	err := withSourceFs(memFs, func() error {
+		var err error
+		manifest, err = indexModule(ctx, "", modulePath, modDestFolder, memRoot, zipOpts, progressCallback)
+		return err
+	})
+	err = reportFailures(modDestFolder, err, opts)
+	// In keep-going mode, the packages indexed despite failures are linked too
+	if manifest == nil {
+		return err
+	}
+	if saveErr := saveManifest(modDestFolder, manifest); saveErr != nil {
+		return errors.Join(err, saveErr)
+	}
+	return errors.Join(err, linkModules(destFolder, []ManifestModule{{
+		Path:     modulePath,
+		Version:  version,
+		Dir:      modDir,
+		Manifest: path.Join(modDir, manifestFileName),
+	}}))
+}
+
+// unzipModule copies the files of a module zip into fs under root, dropping the zip's module@version/ prefix
+func unzipModule(zipPath, modulePath, version string, fs afero.Fs, root string) error {
+	reader, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return fmt.Errorf("failed to open module zip %s: %w", zipPath, err)
+	}
+	defer func() { _ = reader.Close() }()
+
+	prefix := modulePath + "@" + version + "/"
+	for _, file := range reader.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+		rel, ok := strings.CutPrefix(file.Name, prefix)
+		if !ok || !filepath.IsLocal(rel) {
+			return fmt.Errorf("unexpected file %s in module zip %s, want prefix %s", file.Name, zipPath, prefix)
+		}
+		if err := copyZipFile(file, fs, filepath.Join(root, filepath.FromSlash(rel))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyZipFile writes the content of a zip entry to dest in fs
+func copyZipFile(file *zip.File, fs afero.Fs, dest string) error {
+	src, err := file.Open()
+	if err != nil {
+		return fmt.Errorf("failed to read %s from module zip: %w", file.Name, err)
+	}
+	defer func() { _ = src.Close() }()
+
+	if err := fs.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	out, err := fs.Create(dest)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, src); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
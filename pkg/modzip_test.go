@@ -0,0 +1,90 @@
+package pkg
+
+import (
+	"archive/zip"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prashantv/gostub"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModuleZipFromProxy(t *testing.T) {
+	zipPath, err := ModuleZipFromProxy("file:///srv/goproxy", "github.com/Azure/azure-sdk-for-go", "v1.2.3")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.FromSlash("/srv/goproxy/github.com/!azure/azure-sdk-for-go/@v/v1.2.3.zip"), zipPath)
+
+	zipPath, err = ModuleZipFromProxy("/srv/goproxy", "example.com/mod", "v0.1.0")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.FromSlash("/srv/goproxy/example.com/mod/@v/v0.1.0.zip"), zipPath)
+
+	_, err = ModuleZipFromProxy("https://proxy.golang.org", "example.com/mod", "v0.1.0")
+	assert.Error(t, err)
+}
+
+func TestIndexModuleZip(t *testing.T) {
+	stub := gostub.Stub(&destFs, afero.NewMemMapFs())
+	defer stub.Reset()
+
+	zipPath := writeTestModuleZip(t, "example.com/greet@v1.0.0/", map[string]string{
+		"go.mod": "module example.com/greet\n\ngo 1.23\n",
+		"greet.go": `package greet
+
+import "strings"
+
+// Greeting is the default greeting
+const Greeting = "hello"
+
+// Greet greets name
+func Greet(name string) string {
+	return strings.Join([]string{Greeting, name}, " ")
+}
+`,
+		"greet_windows.go":    "package greet\n\nfunc platform() string { return \"windows\" }\n",
+		"greet_test.go":       "package greet\n\nfunc helperForTests() {}\n",
+		"loud/loud.go":        "package loud\n\n// Shout greets loudly\nfunc Shout() string { return \"HELLO\" }\n",
+		"testdata/skipped.go": "package skipped\n\nfunc Skipped() {}\n",
+	})
+
+	require.NoError(t, IndexModuleZip(context.Background(), zipPath, "example.com/greet", "v1.0.0", "output", Options{}, nil))
+
+	content, err := afero.ReadFile(destFs, "output/example.com/greet@v1.0.0/func.Greet.goindex")
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "// Import path: example.com/greet\n")
+	assert.Contains(t, string(content), "import \"strings\"\n")
+	assert.Contains(t, string(content), "// Greet greets name\nfunc Greet(name string) string {")
+
+	content, err = afero.ReadFile(destFs, "output/example.com/greet@v1.0.0/loud/func.Shout.goindex")
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "// Import path: example.com/greet/loud\n")
+
+	for _, missing := range []string{"func.helperForTests.goindex", "testdata"} {
+		exists, err := afero.Exists(destFs, "output/example.com/greet@v1.0.0/"+missing)
+		require.NoError(t, err)
+		assert.False(t, exists, "%s should not be indexed", missing)
+	}
+
+	// The OS filesystem is restored after indexing
+	assert.True(t, isOsFs(sourceFs))
+}
+
+// writeTestModuleZip writes a module zip with the given files under prefix and returns its path
+func writeTestModuleZip(t *testing.T, prefix string, files map[string]string) string {
+	zipPath := filepath.Join(t.TempDir(), "module.zip")
+	out, err := os.Create(zipPath)
+	require.NoError(t, err)
+	writer := zip.NewWriter(out)
+	for name, content := range files {
+		entry, err := writer.Create(prefix + name)
+		require.NoError(t, err)
+		_, err = entry.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, writer.Close())
+	require.NoError(t, out.Close())
+	return zipPath
+}
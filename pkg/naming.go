@@ -0,0 +1,75 @@
+package pkg
+
+import (
+	"fmt"
+	"strings"
+)
+
+// IndexFileCollision records symbols of a package whose predictable index file names collided,
+// e.g. several init functions
+type IndexFileCollision struct {
+	IndexFile string   `json:"indexFile"` // The predictable index file name shared by the symbols
+	Files     []string `json:"files"`     // File names actually written, in declaration order; the first keeps IndexFile
+}
+
+// disambiguatable is implemented by every symbol whose index file name can be overridden
+type disambiguatable interface {
+	IndexableSymbol
+	setIndexFileName(name string)
+}
+
+// disambiguateIndexFileNames gives every symbol of the package a unique index file name.
+// The first symbol claiming a name keeps it and later ones get a sequence number (func.init.1.goindex).
+// Packages are loaded for a single build configuration, where init is the only name a package that
+// compiles can declare more than once, but every symbol is checked so no index file is ever overwritten.
+// The returned collisions list every name that had to be disambiguated.
+func disambiguateIndexFileNames(pkgInfo *PackageInfo) []IndexFileCollision {
+	var symbols []disambiguatable
+	for _, c := range pkgInfo.Constants {
+		symbols = append(symbols, c)
+	}
+	for _, v := range pkgInfo.Variables {
+		symbols = append(symbols, v)
+	}
+	for _, t := range pkgInfo.Types {
+		symbols = append(symbols, t)
+	}
+	for _, f := range pkgInfo.Functions {
+		symbols = append(symbols, f)
+	}
+
+	used := make(map[string]bool)
+	var collisions []IndexFileCollision
+	collisionIndex := make(map[string]int)
+	for _, symbol := range symbols {
+		name := symbol.IndexFileName()
+		if !used[name] {
+			used[name] = true
+			continue
+		}
+
+		unique := disambiguatedName(name, used)
+		used[unique] = true
+		symbol.setIndexFileName(unique)
+
+		i, ok := collisionIndex[name]
+		if !ok {
+			i = len(collisions)
+			collisionIndex[name] = i
+			collisions = append(collisions, IndexFileCollision{IndexFile: name, Files: []string{name}})
+		}
+		collisions[i].Files = append(collisions[i].Files, unique)
+	}
+	return collisions
+}
+
+// disambiguatedName returns the first unused variant of name numbered from 1, e.g. func.init.1.goindex
+func disambiguatedName(name string, used map[string]bool) string {
+	stem := strings.TrimSuffix(name, ".goindex")
+	for i := 1; ; i++ {
+		candidate := fmt.Sprintf("%s.%d.goindex", stem, i)
+		if !used[candidate] {
+			return candidate
+		}
+	}
+}
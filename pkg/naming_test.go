@@ -0,0 +1,78 @@
+package pkg
+
+import (
+	"encoding/json"
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"github.com/prashantv/gostub"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDisambiguateIndexFileNames(t *testing.T) {
+	// A package loaded despite type errors may redeclare a name; constants and variables share the var. prefix
+	broken := parseTestFile(t, "broken.go", "package files\n\nconst X = 1\n\nvar X = 2\n\nfunc Open() {}\n")
+	plain := parseTestFile(t, "plain.go", "package files\n\nfunc init() {}\n\nfunc init() {}\n\nfunc init() {}\n")
+
+	pkgInfo := &PackageInfo{
+		Constants: []*ConstantInfo{{Name: "X", Range: &Range{FileInfo: broken}}},
+		Variables: []*VariableInfo{{Name: "X", Range: &Range{FileInfo: broken}}},
+		Functions: []*FunctionInfo{
+			{Name: "Open", Range: &Range{FileInfo: broken}},
+			{Name: "init", Range: &Range{FileInfo: plain}},
+			{Name: "init", Range: &Range{FileInfo: plain}},
+			{Name: "init", Range: &Range{FileInfo: plain}},
+		},
+	}
+
+	collisions := disambiguateIndexFileNames(pkgInfo)
+
+	assert.Equal(t, "var.X.goindex", pkgInfo.Constants[0].IndexFileName())
+	assert.Equal(t, "var.X.1.goindex", pkgInfo.Variables[0].IndexFileName())
+	assert.Equal(t, "func.Open.goindex", pkgInfo.Functions[0].IndexFileName())
+	assert.Equal(t, "func.init.goindex", pkgInfo.Functions[1].IndexFileName())
+	assert.Equal(t, "func.init.1.goindex", pkgInfo.Functions[2].IndexFileName())
+	assert.Equal(t, "func.init.2.goindex", pkgInfo.Functions[3].IndexFileName())
+
+	assert.Equal(t, []IndexFileCollision{
+		{IndexFile: "var.X.goindex", Files: []string{"var.X.goindex", "var.X.1.goindex"}},
+		{IndexFile: "func.init.goindex", Files: []string{"func.init.goindex", "func.init.1.goindex", "func.init.2.goindex"}},
+	}, collisions)
+}
+
+func TestIndexSourceCode_MultipleInitFunctionsAreAllWritten(t *testing.T) {
+	stub := gostub.Stub(&destFs, afero.NewMemMapFs())
+	defer stub.Reset()
+
+	var reported []IndexFileCollision
+	require.NoError(t, IndexSourceCode("testharness/collisions", "github.com/lonegunmanb/gophon/pkg", "output", func(progress ProgressInfo) {
+		reported = append(reported, progress.Collisions...)
+	}))
+
+	first, err := afero.ReadFile(destFs, "output/testharness/collisions/func.init.goindex")
+	require.NoError(t, err)
+	assert.Contains(t, string(first), `registry = append(registry, "a")`)
+
+	second, err := afero.ReadFile(destFs, "output/testharness/collisions/func.init.1.goindex")
+	require.NoError(t, err)
+	assert.Contains(t, string(second), `registry = append(registry, "b")`)
+
+	var manifest Manifest
+	content, err := afero.ReadFile(destFs, "output/testharness/collisions/manifest.json")
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(content, &manifest))
+	assert.Equal(t, []IndexFileCollision{
+		{IndexFile: "func.init.goindex", Files: []string{"func.init.goindex", "func.init.1.goindex"}},
+	}, manifest.Collisions)
+	assert.Equal(t, manifest.Collisions, reported, "Collisions are reported through the progress callback")
+}
+
+// parseTestFile parses Go source into a FileInfo for tests that do not need go/packages
+func parseTestFile(t *testing.T, fileName, source string) *FileInfo {
+	file, err := parser.ParseFile(token.NewFileSet(), fileName, source, parser.ParseComments)
+	require.NoError(t, err)
+	return &FileInfo{File: file, FileName: fileName, content: &source}
+}
@@ -0,0 +1,68 @@
+package pkg
+
+// Options configures how packages are scanned and indexed.
+// The zero value gives the default behaviour.
+type Options struct {
+	// ExcludeDocComments leaves doc comments out of extracted symbol snippets.
+	// By default a symbol's doc comment, or the doc comment of its enclosing
+	// single-spec declaration, is included in the snippet.
+	ExcludeDocComments bool
+
+	// Root is the directory package paths are relative to. When empty, the
+	// current working directory is used. When no base package URL is given,
+	// it is detected from the go.mod of the module containing Root.
+	Root string
+
+	// SkipInternal leaves out internal packages, i.e. packages with an
+	// "internal" path element, which cannot be imported from outside.
+	SkipInternal bool
+
+	// Revision is recorded in the header of every index file and in manifests,
+	// e.g. the commit SHA the sources were read from.
+	Revision string
+
+	// Force re-indexes every package, even when its sources are unchanged
+	// since the last run recorded in the destination's state file.
+	Force bool
+
+	// DryRun lists the stale index files and directories a run would prune
+	// instead of removing them.
+	DryRun bool
+
+	// KeepGoing indexes every package it can when others fail, writes the
+	// manifests of the indexed packages and reports the failures in an
+	// errors.json file in the destination. Stale index files are not pruned
+	// in a run with failures.
+	KeepGoing bool
+
+	// Strict fails packages loaded with syntax or type errors. By default such
+	// packages are indexed with whatever declarations parsed, and the errors
+	// are reported in PackageInfo.Errors and ProgressInfo.Errors.
+	Strict bool
+
+	// SyntaxOnly parses packages with go/parser straight from the source
+	// filesystem instead of loading them with the go command, so neither the
+	// Go toolchain nor downloaded dependencies are needed. Syntax errors are
+	// reported, type errors are not, as imported packages are not loaded.
+	SyntaxOnly bool
+
+	// WriteWorkers is the number of goroutines writing index files while
+	// packages are still being scanned. When zero, it matches the number of
+	// scanning workers, which GOPHON_CPU_LIMIT may reduce.
+	WriteWorkers int
+
+	// moduleRoot is the directory of the module whose build list resolves the
+	// packages under Root, set when Root lies outside it (e.g. in the module cache)
+	moduleRoot string
+
+	// skipPackage reports whether the package at a relative path can be skipped
+	// because its index files are up to date
+	skipPackage func(pkgPath string) bool
+
+	// packageFailed is told about the package at a relative path that failed to load
+	packageFailed func(pkgPath string)
+
+	// loadBatchSize is the number of packages loaded per packages.Load call,
+	// defaultLoadBatchSize when zero
+	loadBatchSize int
+}
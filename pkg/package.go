@@ -2,9 +2,17 @@ package pkg
 
 // PackageInfo holds comprehensive information about a scanned package
 type PackageInfo struct {
+	PkgPath   string // Import path reported by go/packages, e.g. github.com/user/project/cmd
+	Name      string // Package name declared in the package clause, e.g. main
+	Dir       string // Directory containing the package's files
 	Files     []*FileInfo
 	Constants []*ConstantInfo
 	Variables []*VariableInfo
 	Types     []*TypeInfo
 	Functions []*FunctionInfo
+	// Collisions lists index file names shared by several symbols and how they were disambiguated
+	Collisions []IndexFileCollision
+	// Errors lists the syntax and type errors the package was loaded with; the declarations that parsed
+	// are extracted regardless
+	Errors []string
 }
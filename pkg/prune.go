@@ -0,0 +1,136 @@
+package pkg
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+// pruneStaleIndexFiles removes the index files under the subtree index directory of destFolder that no
+// manifest of the run lists, such as files of deleted or renamed symbols, together with manifests of packages
+// that are gone and directories left empty. Only the subtree the run indexed is pruned, "." for the whole
+// destination, as the index files elsewhere belong to packages the run did not scan. Subdirectories holding
+// their own state file belong to other runs (nested modules, dependencies, the standard library) and are left
+// alone, and destinations without a state file are not pruned at all. With dryRun set nothing is removed.
+// It returns the removed paths, or the paths that would be removed.
+func pruneStaleIndexFiles(destFolder, subtree string, manifests []*Manifest, dryRun bool) ([]string, error) {
+	// Only destinations written by an earlier run are pruned, so unrelated files are never touched
+	if exists, _ := afero.Exists(destFs, filepath.Join(destFolder, stateFileName)); !exists {
+		return nil, nil
+	}
+	walkRoot := filepath.Join(destFolder, filepath.FromSlash(subtree))
+	if exists, _ := afero.DirExists(destFs, walkRoot); !exists {
+		return nil, nil
+	}
+
+	ownedFiles := make(map[string]bool)
+	ownedDirs := map[string]bool{".": true}
+	for _, manifest := range manifests {
+		ownedDirs[manifest.Path] = true
+		for _, symbol := range manifest.Symbols {
+			ownedFiles[symbol.IndexFile] = true
+		}
+	}
+
+	var stale []string
+	var dirs []string
+	staleManifests := make(map[string]string)
+	staleIndexDirs := make(map[string]bool)
+	err := afero.Walk(destFs, walkRoot, func(filePath string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(destFolder, filePath)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if info.IsDir() {
+			if filePath == walkRoot {
+				return nil
+			}
+			if exists, _ := afero.Exists(destFs, filepath.Join(filePath, stateFileName)); exists {
+				return filepath.SkipDir
+			}
+			dirs = append(dirs, filePath)
+			return nil
+		}
+
+		switch {
+		case strings.HasSuffix(rel, ".goindex") && !ownedFiles[rel]:
+			stale = append(stale, filePath)
+			staleIndexDirs[path.Dir(rel)] = true
+		case info.Name() == manifestFileName && !ownedDirs[path.Dir(rel)]:
+			staleManifests[path.Dir(rel)] = filePath
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan %s for stale index files: %w", walkRoot, err)
+	}
+
+	// Manifests are only removed together with the index files of a package that is gone
+	for dir, filePath := range staleManifests {
+		if staleIndexDirs[dir] {
+			stale = append(stale, filePath)
+		}
+	}
+	sort.Strings(stale)
+
+	if dryRun {
+		return append(stale, emptyAfterPruning(dirs, stale)...), nil
+	}
+
+	var removed []string
+	for _, filePath := range stale {
+		if err := destFs.Remove(filePath); err != nil {
+			return removed, fmt.Errorf("failed to remove stale index file %s: %w", filePath, err)
+		}
+		removed = append(removed, filePath)
+	}
+
+	// Remove directories left empty, deepest first
+	sort.Sort(sort.Reverse(sort.StringSlice(dirs)))
+	for _, dir := range dirs {
+		entries, err := afero.ReadDir(destFs, dir)
+		if err != nil || len(entries) > 0 {
+			continue
+		}
+		if err := destFs.Remove(dir); err != nil {
+			return removed, fmt.Errorf("failed to remove empty directory %s: %w", dir, err)
+		}
+		removed = append(removed, dir)
+	}
+	return removed, nil
+}
+
+// emptyAfterPruning returns the directories that would be left empty once the stale files are removed
+func emptyAfterPruning(dirs, stale []string) []string {
+	remaining := make(map[string]int)
+	for _, dir := range dirs {
+		entries, err := afero.ReadDir(destFs, dir)
+		if err == nil {
+			remaining[dir] = len(entries)
+		}
+	}
+	for _, filePath := range stale {
+		remaining[filepath.Dir(filePath)]--
+	}
+
+	var empty []string
+	sort.Sort(sort.Reverse(sort.StringSlice(dirs)))
+	for _, dir := range dirs {
+		if count, ok := remaining[dir]; ok && count <= 0 {
+			empty = append(empty, dir)
+			// The parent loses this directory as an entry
+			remaining[filepath.Dir(dir)]--
+		}
+	}
+	return empty
+}
@@ -0,0 +1,110 @@
+package pkg
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/prashantv/gostub"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndexSourceCode_PrunesStaleIndexFiles(t *testing.T) {
+	srcFs := afero.NewMemMapFs()
+	stubs := gostub.Stub(&destFs, afero.NewMemMapFs())
+	stubs.Stub(&sourceFs, srcFs)
+	defer stubs.Reset()
+
+	require.NoError(t, afero.WriteFile(srcFs, "/src/go.mod", []byte("module example.com/prune\n"), 0644))
+	require.NoError(t, afero.WriteFile(srcFs, "/src/a/a.go", []byte("package a\n\nfunc A() {}\n\nfunc Kept() {}\n"), 0644))
+	require.NoError(t, afero.WriteFile(srcFs, "/src/b/c/c.go", []byte("package c\n\nfunc C() {}\n"), 0644))
+	opts := Options{Root: "/src"}
+	require.NoError(t, IndexSourceCodeWithOptions("", "", "output", opts, nil))
+
+	// Files that are not index files and trees owned by other runs are never pruned
+	require.NoError(t, afero.WriteFile(destFs, "output/notes.txt", []byte("keep"), 0644))
+	require.NoError(t, afero.WriteFile(destFs, "output/example.com/dep@v1.0.0/"+stateFileName, []byte("{}"), 0644))
+	require.NoError(t, afero.WriteFile(destFs, "output/example.com/dep@v1.0.0/func.Dep.goindex", []byte("package dep"), 0644))
+
+	// Rename a function and delete a package
+	require.NoError(t, afero.WriteFile(srcFs, "/src/a/a.go", []byte("package a\n\nfunc A2() {}\n\nfunc Kept() {}\n"), 0644))
+	require.NoError(t, srcFs.RemoveAll("/src/b"))
+
+	stale := []string{
+		filepath.Join("output", "a", "func.A.goindex"),
+		filepath.Join("output", "b", "c", "func.C.goindex"),
+		filepath.Join("output", "b", "c", "manifest.json"),
+	}
+	emptied := []string{
+		filepath.Join("output", "b", "c"),
+		filepath.Join("output", "b"),
+	}
+
+	// A dry run lists what would be removed without touching anything
+	dryRunOpts := opts
+	dryRunOpts.DryRun = true
+	var pruned []string
+	_, err := indexModule(context.Background(), "", "example.com/prune", "output", "/src", dryRunOpts, func(progress ProgressInfo) {
+		pruned = append(pruned, progress.Pruned...)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, append(stale, emptied...), pruned, "Pruned files are reported through the progress callback")
+	for _, filePath := range stale {
+		exists, err := afero.Exists(destFs, filePath)
+		require.NoError(t, err)
+		assert.True(t, exists, "Dry run must not remove %s", filePath)
+	}
+
+	require.NoError(t, IndexSourceCodeWithOptions("", "", "output", opts, nil))
+	for _, filePath := range append(stale, emptied...) {
+		exists, err := afero.Exists(destFs, filePath)
+		require.NoError(t, err)
+		assert.False(t, exists, "%s should be pruned", filePath)
+	}
+	for _, filePath := range []string{
+		"output/a/func.A2.goindex",
+		"output/a/func.Kept.goindex",
+		"output/a/manifest.json",
+		"output/notes.txt",
+		"output/example.com/dep@v1.0.0/func.Dep.goindex",
+	} {
+		exists, err := afero.Exists(destFs, filePath)
+		require.NoError(t, err)
+		assert.True(t, exists, "%s should be kept", filePath)
+	}
+}
+
+func TestIndexSourceCode_SubtreeRunPrunesOnlyItsSubtree(t *testing.T) {
+	srcFs := afero.NewMemMapFs()
+	stubs := gostub.Stub(&destFs, afero.NewMemMapFs())
+	stubs.Stub(&sourceFs, srcFs)
+	defer stubs.Reset()
+
+	require.NoError(t, afero.WriteFile(srcFs, "/src/go.mod", []byte("module example.com/prune\n"), 0644))
+	require.NoError(t, afero.WriteFile(srcFs, "/src/a/a.go", []byte("package a\n\nfunc A() {}\n"), 0644))
+	require.NoError(t, afero.WriteFile(srcFs, "/src/b/b.go", []byte("package b\n\nfunc B() {}\n"), 0644))
+	require.NoError(t, afero.WriteFile(srcFs, "/src/b/c/c.go", []byte("package c\n\nfunc C() {}\n"), 0644))
+	opts := Options{Root: "/src"}
+	require.NoError(t, IndexSourceCodeWithOptions("", "", "output", opts, nil))
+
+	// Re-index the b subtree after renaming its function
+	require.NoError(t, afero.WriteFile(srcFs, "/src/b/b.go", []byte("package b\n\nfunc B2() {}\n"), 0644))
+	require.NoError(t, IndexSourceCodeWithOptions("b", "example.com/prune", "output", opts, nil))
+
+	exists, err := afero.Exists(destFs, "output/b/func.B.goindex")
+	require.NoError(t, err)
+	assert.False(t, exists, "Stale index files within the subtree are pruned")
+	for _, filePath := range []string{
+		"output/a/func.A.goindex",
+		"output/a/manifest.json",
+		"output/b/func.B2.goindex",
+		"output/b/c/func.C.goindex",
+		"output/b/c/manifest.json",
+	} {
+		exists, err := afero.Exists(destFs, filePath)
+		require.NoError(t, err)
+		assert.True(t, exists, "%s should be kept", filePath)
+	}
+}
@@ -1,6 +1,9 @@
 package pkg
 
-import "strings"
+import (
+	"go/ast"
+	"strings"
+)
 
 // Range represents a range of lines within a file, indicating start and end line numbers.
 // It embeds *FileInfo to provide access to file information.
@@ -39,3 +42,12 @@ func (r *Range) String() string {
 
 	return strings.ReplaceAll(strings.Join(selectedLines, "\n"), "\r", "")
 }
+
+// covers reports whether the range spans every line of the given node
+func (r *Range) covers(node ast.Node) bool {
+	if r.FileInfo == nil {
+		return false
+	}
+	start, end := r.line(node.Pos()), r.line(node.End())
+	return start > 0 && r.StartLine <= start && r.EndLine >= end
+}
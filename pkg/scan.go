@@ -1,16 +1,21 @@
 package pkg
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/spf13/afero"
 	"go/ast"
 	"go/token"
+	"go/types"
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"golang.org/x/tools/go/packages"
@@ -66,27 +71,51 @@ func max(a, b int) int {
 
 // ProgressInfo represents progress information during package scanning
 type ProgressInfo struct {
-	Completed  int     // Number of packages completed
-	Total      int     // Total number of packages discovered so far
-	Current    string  // Currently processing package path
-	Percentage float64 // Completion percentage (completed/total * 100)
+	Completed  int                  // Number of packages completed
+	Total      int                  // Total number of packages discovered so far
+	Current    string               // Currently processing package path
+	Percentage float64              // Completion percentage (completed/total * 100)
+	Errors     []string             // Syntax and type errors of the package in Current, set once it is scanned
+	Collisions []IndexFileCollision // Index file names of the package in Current that had to be disambiguated, set once it is scanned
+	Pruned     []string             // Stale index files removed once the module in Current is indexed, or that would be removed with Options.DryRun
+	Warnings   []string             // Problems that did not stop indexing, such as an unreadable state file
+	Reindexed  []string             // Packages Watch re-indexed after their files changed
+	Unindexed  []string             // Packages whose index files Watch removed as they no longer exist or have no symbols
 }
 
 // ScanSinglePackage scans the specified package and returns comprehensive information
 func ScanSinglePackage(pkgPath, basePkgUrl string) (*PackageInfo, error) {
-	// Use relative path for packages.Load to work with local filesystem
-	var loadPath string
-	if pkgPath == "" {
-		loadPath = "."
-	} else {
-		loadPath = "./" + pkgPath
-	}
+	return ScanSinglePackageWithOptions(pkgPath, basePkgUrl, Options{})
+}
 
-	cfg := &packages.Config{
-		Mode: packages.NeedFiles | packages.NeedName | packages.NeedImports | packages.NeedTypes | packages.NeedSyntax,
+// ScanSinglePackageWithOptions scans the specified package using the given options
+// and returns comprehensive information
+func ScanSinglePackageWithOptions(pkgPath, basePkgUrl string, opts Options) (*PackageInfo, error) {
+	return ScanSinglePackageContext(context.Background(), pkgPath, basePkgUrl, opts)
+}
+
+// ScanSinglePackageContext behaves like ScanSinglePackageWithOptions, stopping the go command
+// it runs to load the package when ctx is done
+func ScanSinglePackageContext(ctx context.Context, pkgPath, basePkgUrl string, opts Options) (*PackageInfo, error) {
+	// packages.Load runs the go command, which only sees the OS filesystem.
+	// Syntax-only scans parse the sources directly and need neither the toolchain nor dependencies.
+	if opts.SyntaxOnly || !isOsFs(sourceFs) {
+		root := opts.Root
+		if root == "" {
+			root = "."
+		}
+		pkg, err := loadPackageFromFs(filepath.Join(root, filepath.FromSlash(pkgPath)), joinPackageUrl(basePkgUrl, pkgPath))
+		if err != nil {
+			return nil, err
+		}
+		return checkLoadErrors(scanLoadedPackage(pkg, pkgPath, basePkgUrl, opts), opts)
 	}
 
-	pkgs, err := packages.Load(cfg, loadPath)
+	cfg, patterns, err := packagesConfig(ctx, opts, pkgPath)
+	if err != nil {
+		return nil, err
+	}
+	pkgs, err := packages.Load(cfg, patterns...)
 	if err != nil {
 		return nil, err
 	}
@@ -95,25 +124,80 @@ func ScanSinglePackage(pkgPath, basePkgUrl string) (*PackageInfo, error) {
 		return &PackageInfo{}, nil
 	}
 
-	pkg := pkgs[0]
+	return checkLoadErrors(scanLoadedPackage(pkgs[0], pkgPath, basePkgUrl, opts), opts)
+}
 
-	// Determine the actual package path based on the declared package name
-	// Split the directory path and replace the last part with the actual package name
-	pathParts := []string{basePkgUrl}
-	if pkgPath != "" {
-		sep := string(filepath.Separator)
-		parts := strings.Split(pkgPath, sep)
-		if !strings.Contains(pkgPath, sep) && strings.Contains(pkgPath, "/") {
-			parts = strings.Split(pkgPath, "/")
+// packagesConfig returns the packages.Load configuration for scanning with opts,
+// and the load pattern of the package at each relative path
+func packagesConfig(ctx context.Context, opts Options, pkgPaths ...string) (*packages.Config, []string, error) {
+	cfg := &packages.Config{
+		Context: ctx,
+		Mode:    packages.NeedFiles | packages.NeedName | packages.NeedImports | packages.NeedTypes | packages.NeedTypesInfo | packages.NeedSyntax,
+		Dir:     opts.Root,
+	}
+
+	// Use relative paths for packages.Load to work with local filesystem
+	patterns := make([]string, 0, len(pkgPaths))
+	for _, pkgPath := range pkgPaths {
+		if pkgPath == "" {
+			patterns = append(patterns, ".")
+		} else {
+			patterns = append(patterns, "./"+pkgPath)
 		}
-		if len(parts) > 1 {
-			pathParts = append(pathParts, parts[:len(parts)-1]...)
+	}
+
+	// Packages outside the module being built, such as dependencies in the module cache,
+	// are loaded by directory from the context of that module
+	if opts.moduleRoot != "" {
+		absRoot, err := filepath.Abs(opts.Root)
+		if err != nil {
+			return nil, nil, err
 		}
-		pathParts = append(pathParts, pkg.Name)
-	} else {
-		pathParts = append(pathParts, pkg.Name)
+		cfg.Dir = opts.moduleRoot
+		for i, pkgPath := range pkgPaths {
+			patterns[i] = filepath.Join(absRoot, filepath.FromSlash(pkgPath))
+		}
+	}
+	return cfg, patterns, nil
+}
+
+// checkLoadErrors fails a package loaded with errors in strict mode
+func checkLoadErrors(packageInfo *PackageInfo, opts Options) (*PackageInfo, error) {
+	if opts.Strict && len(packageInfo.Errors) > 0 {
+		return nil, fmt.Errorf("package has %d error(s): %s", len(packageInfo.Errors), strings.Join(packageInfo.Errors, "; "))
+	}
+	return packageInfo, nil
+}
+
+// loadErrors returns the errors a package was loaded with. A directory without Go files for
+// the current build is an empty package rather than a broken one. The go command repeats syntax and
+// type errors when it compiles the package, so its errors are only kept when there are no others.
+func loadErrors(pkg *packages.Package) []string {
+	if len(pkg.GoFiles) == 0 {
+		return nil
+	}
+	var errs, listErrs []string
+	for _, err := range pkg.Errors {
+		if err.Kind == packages.ListError {
+			listErrs = append(listErrs, err.Error())
+			continue
+		}
+		errs = append(errs, err.Error())
+	}
+	if len(errs) == 0 {
+		return listErrs
+	}
+	return errs
+}
+
+// scanLoadedPackage extracts the symbols of a loaded package
+func scanLoadedPackage(pkg *packages.Package, pkgPath, basePkgUrl string, opts Options) *PackageInfo {
+	// Use the real import path reported by the go command; fall back to the directory-based
+	// path only when the go command could not resolve one
+	actualPkgPath := pkg.PkgPath
+	if actualPkgPath == "" || actualPkgPath == "command-line-arguments" {
+		actualPkgPath = joinPackageUrl(basePkgUrl, pkgPath)
 	}
-	actualPkgPath := strings.Join(pathParts, "/")
 
 	var files []*FileInfo
 	var constants []*ConstantInfo
@@ -129,9 +213,12 @@ func ScanSinglePackage(pkgPath, basePkgUrl string) (*PackageInfo, error) {
 
 		fileName := pkg.Fset.Position(file.Pos()).Filename
 		fileInfo := &FileInfo{
-			File:     file,
-			FileName: fileName,
-			Package:  actualPkgPath,
+			File:      file,
+			FileName:  fileName,
+			Package:   actualPkgPath,
+			fset:      pkg.Fset,
+			typesInfo: pkg.TypesInfo,
+			fs:        sourceFs,
 		}
 		files = append(files, fileInfo)
 
@@ -140,42 +227,161 @@ func ScanSinglePackage(pkgPath, basePkgUrl string) (*PackageInfo, error) {
 			if genDecl, ok := decl.(*ast.GenDecl); ok {
 				switch genDecl.Tok {
 				case token.CONST:
-					constants = append(constants, extractDeclarations(actualPkgPath, genDecl, pkg, fileInfo, func(name string, pkgPath string, rangeInfo *Range) *ConstantInfo {
+					constants = append(constants, extractDeclarations(actualPkgPath, genDecl, pkg, fileInfo, opts, func(name string, pkgPath string, spec *ast.ValueSpec, rangeInfo *Range) *ConstantInfo {
+						value, valueType := constantValue(pkg, name)
 						return &ConstantInfo{
 							GenDecl: genDecl,
+							Spec:    spec,
 							Name:    name,
 							Range:   rangeInfo,
+							Value:   value,
+							Type:    valueType,
 						}
 					})...)
 				case token.VAR:
-					variables = append(variables, extractDeclarations(actualPkgPath, genDecl, pkg, fileInfo, func(name string, pkgPath string, rangeInfo *Range) *VariableInfo {
+					variables = append(variables, extractDeclarations(actualPkgPath, genDecl, pkg, fileInfo, opts, func(name string, pkgPath string, spec *ast.ValueSpec, rangeInfo *Range) *VariableInfo {
 						return &VariableInfo{
 							GenDecl: genDecl,
+							Spec:    spec,
 							Name:    name,
 							Range:   rangeInfo,
 						}
 					})...)
 				case token.TYPE:
-					types = append(types, extractTypeDeclarations(genDecl, pkg, fileInfo)...)
+					types = append(types, extractTypeDeclarations(genDecl, pkg, fileInfo, opts)...)
 				}
 			} else if funcDecl, ok := decl.(*ast.FuncDecl); ok {
-				functions = append(functions, extractFunctionDeclarations(funcDecl, pkg, fileInfo)...)
+				functions = append(functions, extractFunctionDeclarations(funcDecl, pkg, fileInfo, opts)...)
 			}
 		}
 	}
 
-	return &PackageInfo{
+	attachMethods(types, functions)
+
+	packageInfo := &PackageInfo{
+		PkgPath:   actualPkgPath,
+		Name:      pkg.Name,
+		Dir:       pkg.Dir,
 		Files:     files,
 		Constants: constants,
 		Variables: variables,
 		Types:     types,
 		Functions: functions,
-	}, nil
+		Errors:    loadErrors(pkg),
+	}
+	packageInfo.Collisions = disambiguateIndexFileNames(packageInfo)
+	return packageInfo
+}
+
+// newRange creates a Range covering the lines between the start and end positions
+func newRange(fset *token.FileSet, fileInfo *FileInfo, start, end token.Pos) *Range {
+	return &Range{
+		FileInfo:  fileInfo,
+		StartLine: fset.Position(start).Line,
+		EndLine:   fset.Position(end).Line,
+	}
+}
+
+// newSpecRange creates the Range of a spec inside a const, var or type declaration.
+// Unless doc comments are excluded, the range starts at the spec's own doc comment or,
+// for single-spec declarations, covers the whole declaration from its doc comment.
+// The range ends after the spec's trailing comment, which may span several lines.
+func newSpecRange(fset *token.FileSet, fileInfo *FileInfo, genDecl *ast.GenDecl, spec ast.Spec, specDoc *ast.CommentGroup, opts Options) *Range {
+	start, end := spec.Pos(), spec.End()
+	if !opts.ExcludeDocComments {
+		if specDoc != nil {
+			start = specDoc.Pos()
+		} else if len(genDecl.Specs) == 1 && genDecl.Doc != nil {
+			start, end = genDecl.Doc.Pos(), genDecl.End()
+		}
+	}
+	var comment *ast.CommentGroup
+	switch s := spec.(type) {
+	case *ast.ValueSpec:
+		comment = s.Comment
+	case *ast.TypeSpec:
+		comment = s.Comment
+	}
+	if comment != nil && comment.End() > end {
+		end = comment.End()
+	}
+	return newRange(fset, fileInfo, start, end)
+}
+
+// attachMethods records every method on the type declared with its receiver, for both pointer and value receivers
+func attachMethods(types []*TypeInfo, functions []*FunctionInfo) {
+	typesByName := make(map[string]*TypeInfo, len(types))
+	for _, t := range types {
+		typesByName[t.Name] = t
+	}
+
+	for _, function := range functions {
+		if function.ReceiverType == "" {
+			continue
+		}
+		if t, ok := typesByName[strings.TrimPrefix(function.ReceiverType, "*")]; ok {
+			t.Methods = append(t.Methods, function)
+		}
+	}
+}
+
+// isEnumGroup reports whether a parenthesized const declaration uses iota or implicit repetition,
+// in which case a single spec is meaningless without the rest of the group
+func isEnumGroup(genDecl *ast.GenDecl) bool {
+	if genDecl.Tok != token.CONST || !genDecl.Lparen.IsValid() {
+		return false
+	}
+	for _, spec := range genDecl.Specs {
+		valueSpec, ok := spec.(*ast.ValueSpec)
+		if !ok {
+			continue
+		}
+		if len(valueSpec.Values) == 0 {
+			return true
+		}
+		usesIota := false
+		for _, value := range valueSpec.Values {
+			ast.Inspect(value, func(n ast.Node) bool {
+				if ident, ok := n.(*ast.Ident); ok && ident.Name == "iota" {
+					usesIota = true
+				}
+				return !usesIota
+			})
+		}
+		if usesIota {
+			return true
+		}
+	}
+	return false
+}
+
+// constantValue returns the exact value and type of a package-level constant computed by go/types,
+// or empty strings when type information is unavailable. Long strings are not shortened and
+// floats are given as exact fractions rather than rounded.
+func constantValue(pkg *packages.Package, name string) (string, string) {
+	if pkg.Types == nil {
+		return "", ""
+	}
+	c, ok := pkg.Types.Scope().Lookup(name).(*types.Const)
+	if !ok || c.Val() == nil {
+		return "", ""
+	}
+	return c.Val().ExactString(), types.TypeString(c.Type(), types.RelativeTo(pkg.Types))
+}
+
+// newDeclRange creates a Range covering a whole declaration, starting at its doc comment unless excluded
+func newDeclRange(fset *token.FileSet, fileInfo *FileInfo, genDecl *ast.GenDecl, opts Options) *Range {
+	start := genDecl.Pos()
+	if genDecl.Doc != nil && !opts.ExcludeDocComments {
+		start = genDecl.Doc.Pos()
+	}
+	return newRange(fset, fileInfo, start, genDecl.End())
 }
 
 // Generic function to extract declarations from AST
-func extractDeclarations[T any](pkgPath string, genDecl *ast.GenDecl, pkg *packages.Package, fileInfo *FileInfo, createFunc func(name string, pkgPath string, rangeInfo *Range) *T) []*T {
+func extractDeclarations[T any](pkgPath string, genDecl *ast.GenDecl, pkg *packages.Package, fileInfo *FileInfo, opts Options, createFunc func(name string, pkgPath string, spec *ast.ValueSpec, rangeInfo *Range) *T) []*T {
 	var results []*T
+	enumGroup := isEnumGroup(genDecl)
 	for _, spec := range genDecl.Specs {
 		if valueSpec, ok := spec.(*ast.ValueSpec); ok {
 			for _, name := range valueSpec.Names {
@@ -184,17 +390,15 @@ func extractDeclarations[T any](pkgPath string, genDecl *ast.GenDecl, pkg *packa
 					continue
 				}
 
-				// Get line numbers for the declaration
-				startPos := pkg.Fset.Position(spec.Pos())
-				endPos := pkg.Fset.Position(spec.End())
-
-				rangeInfo := &Range{
-					FileInfo:  fileInfo,
-					StartLine: startPos.Line,
-					EndLine:   endPos.Line,
+				// Get line numbers for the declaration; constants of an enum-style group carry the whole group
+				var rangeInfo *Range
+				if enumGroup {
+					rangeInfo = newDeclRange(pkg.Fset, fileInfo, genDecl, opts)
+				} else {
+					rangeInfo = newSpecRange(pkg.Fset, fileInfo, genDecl, spec, valueSpec.Doc, opts)
 				}
 
-				result := createFunc(name.Name, pkgPath, rangeInfo)
+				result := createFunc(name.Name, pkgPath, valueSpec, rangeInfo)
 				results = append(results, result)
 			}
 		}
@@ -203,24 +407,18 @@ func extractDeclarations[T any](pkgPath string, genDecl *ast.GenDecl, pkg *packa
 }
 
 // Extract type declarations from AST
-func extractTypeDeclarations(genDecl *ast.GenDecl, pkg *packages.Package, fileInfo *FileInfo) []*TypeInfo {
+func extractTypeDeclarations(genDecl *ast.GenDecl, pkg *packages.Package, fileInfo *FileInfo, opts Options) []*TypeInfo {
 	var results []*TypeInfo
 	for _, spec := range genDecl.Specs {
 		if typeSpec, ok := spec.(*ast.TypeSpec); ok {
 			// Get line numbers for the type declaration
-			startPos := pkg.Fset.Position(typeSpec.Pos())
-			endPos := pkg.Fset.Position(typeSpec.End())
-
-			rangeInfo := &Range{
-				FileInfo:  fileInfo,
-				StartLine: startPos.Line,
-				EndLine:   endPos.Line,
-			}
+			rangeInfo := newSpecRange(pkg.Fset, fileInfo, genDecl, typeSpec, typeSpec.Doc, opts)
 
 			results = append(results, &TypeInfo{
 				Name:    typeSpec.Name.Name,
 				Range:   rangeInfo,
 				GenDecl: genDecl,
+				Spec:    typeSpec,
 			})
 		}
 	}
@@ -228,44 +426,68 @@ func extractTypeDeclarations(genDecl *ast.GenDecl, pkg *packages.Package, fileIn
 }
 
 // Extract function declarations from AST
-func extractFunctionDeclarations(funcDecl *ast.FuncDecl, pkg *packages.Package, fileInfo *FileInfo) []*FunctionInfo {
+func extractFunctionDeclarations(funcDecl *ast.FuncDecl, pkg *packages.Package, fileInfo *FileInfo, opts Options) []*FunctionInfo {
 	var results []*FunctionInfo
 
-	// Get line numbers for the function declaration
-	startPos := pkg.Fset.Position(funcDecl.Pos())
-	endPos := pkg.Fset.Position(funcDecl.End())
-
-	rangeInfo := &Range{
-		FileInfo:  fileInfo,
-		StartLine: startPos.Line,
-		EndLine:   endPos.Line,
+	// Get line numbers for the function declaration, including its doc comment unless excluded
+	start := funcDecl.Pos()
+	if funcDecl.Doc != nil && !opts.ExcludeDocComments {
+		start = funcDecl.Doc.Pos()
 	}
+	rangeInfo := newRange(pkg.Fset, fileInfo, start, funcDecl.End())
 
 	// Determine receiver type (empty for functions, populated for methods)
 	receiverType := ""
+	var receiverTypeParams []string
 	if funcDecl.Recv != nil && len(funcDecl.Recv.List) > 0 {
-		// Extract receiver type
-		if starExpr, ok := funcDecl.Recv.List[0].Type.(*ast.StarExpr); ok {
-			// Pointer receiver like *Service
-			if ident, ok := starExpr.X.(*ast.Ident); ok {
-				receiverType = "*" + ident.Name
-			}
-		} else if ident, ok := funcDecl.Recv.List[0].Type.(*ast.Ident); ok {
-			// Value receiver like Service
-			receiverType = ident.Name
-		}
+		receiverType, receiverTypeParams = receiverTypeName(funcDecl.Recv.List[0].Type)
 	}
 
 	results = append(results, &FunctionInfo{
-		Range:        rangeInfo,
-		FuncDecl:     funcDecl,
-		Name:         funcDecl.Name.Name,
-		ReceiverType: receiverType,
+		Range:              rangeInfo,
+		FuncDecl:           funcDecl,
+		Name:               funcDecl.Name.Name,
+		ReceiverType:       receiverType,
+		ReceiverTypeParams: receiverTypeParams,
 	})
 
 	return results
 }
 
+// receiverTypeName returns the name of a method's receiver type, prefixed with * for pointer receivers,
+// together with the type parameter names of generic receivers such as *List[T] or Pair[K, V]
+func receiverTypeName(expr ast.Expr) (string, []string) {
+	switch t := expr.(type) {
+	case *ast.ParenExpr:
+		return receiverTypeName(t.X)
+	case *ast.StarExpr:
+		// Pointer receiver like *Service or *List[T]
+		name, typeParams := receiverTypeName(t.X)
+		if name == "" {
+			return "", nil
+		}
+		return "*" + name, typeParams
+	case *ast.Ident:
+		// Value receiver like Service
+		return t.Name, nil
+	case *ast.IndexExpr:
+		// Generic receiver with a single type parameter like List[T]
+		if ident, ok := t.X.(*ast.Ident); ok {
+			return ident.Name, []string{types.ExprString(t.Index)}
+		}
+	case *ast.IndexListExpr:
+		// Generic receiver with several type parameters like Pair[K, V]
+		if ident, ok := t.X.(*ast.Ident); ok {
+			var typeParams []string
+			for _, index := range t.Indices {
+				typeParams = append(typeParams, types.ExprString(index))
+			}
+			return ident.Name, typeParams
+		}
+	}
+	return "", nil
+}
+
 // ScanPackagesRecursively recursively scans all packages starting from the specified path
 // and invokes the callback function for each package found. It uses afero.Fs for file system operations
 // to enable easy testing with mocked file systems.
@@ -277,94 +499,168 @@ func extractFunctionDeclarations(funcDecl *ast.FuncDecl, pkg *packages.Package,
 //   - callback: Function called for each package, receives *PackageInfo and full package URL
 //   - progressCallback: Optional callback for progress updates, receives ProgressInfo
 func ScanPackagesRecursively(pkgPath, basePkgUrl string, callback func(*PackageInfo, string), progressCallback func(ProgressInfo)) error {
+	return ScanPackagesRecursivelyWithOptions(pkgPath, basePkgUrl, Options{}, callback, progressCallback)
+}
+
+// ScanPackagesRecursivelyWithOptions behaves like ScanPackagesRecursively, scanning every package with the given options
+func ScanPackagesRecursivelyWithOptions(pkgPath, basePkgUrl string, opts Options, callback func(*PackageInfo, string), progressCallback func(ProgressInfo)) error {
+	return ScanPackagesRecursivelyContext(context.Background(), pkgPath, basePkgUrl, opts, callback, progressCallback)
+}
+
+// ScanPackagesRecursivelyContext behaves like ScanPackagesRecursivelyWithOptions until ctx is done.
+// It then stops handing out packages, waits for the workers to abandon the packages they are loading
+// and returns ctx.Err(); callback has been invoked for the packages scanned until then.
+// callback is invoked from one goroutine at a time.
+func ScanPackagesRecursivelyContext(ctx context.Context, pkgPath, basePkgUrl string, opts Options, callback func(*PackageInfo, string), progressCallback func(ProgressInfo)) error {
+	return scanPipeline(ctx, pkgPath, basePkgUrl, opts, 1, callback, progressCallback)
+}
+
+// scanPipeline scans every package under pkgPath in stages connected by bounded channels: packages are
+// discovered, loaded a batch at a time, extracted by a pool of workers, and handed to sink from a pool of
+// sinkWorkers goroutines, so sink must be safe for concurrent use when sinkWorkers is above one.
+// A package counts as completed once sink returns.
+func scanPipeline(ctx context.Context, pkgPath, basePkgUrl string, opts Options, sinkWorkers int, sink func(*PackageInfo, string), progressCallback func(ProgressInfo)) error {
+	basePkgUrl, err := resolveBasePkgUrl(basePkgUrl, opts)
+	if err != nil {
+		return err
+	}
+
 	// Get CPU throttling configuration
 	throttleConfig := getCPUThrottleConfig()
-	
-	// First, discover all packages to get accurate total count
-	allPackages := findSubPackages(pkgPath)
-	if pkgPath != "" || len(allPackages) == 0 {
-		// Include the root package if we're scanning from a specific path or if no sub-packages found
-		allPackages = append([]string{pkgPath}, allPackages...)
+
+	// Discover: find all packages first to get an accurate total count.
+	// The starting package is always included; a directory without Go files yields no symbols
+	allPackages := append([]string{pkgPath}, findSubPackages(opts.Root, pkgPath)...)
+	if opts.SkipInternal {
+		allPackages = slices.DeleteFunc(allPackages, isInternalPackage)
 	}
 
-	var completedWork int
 	totalDiscovered := len(allPackages)
-	var mu sync.Mutex
-	var wg sync.WaitGroup
-
-	// Helper function to report progress
-	reportProgress := func(current string) {
-		mu.Lock()
-		defer mu.Unlock()
+	var completedWork atomic.Int64
 
+	// Progress is counted without locking; the mutex only keeps progressCallback from running concurrently
+	var progressMu sync.Mutex
+	reportProgress := func(current string, errs []string, collisions []IndexFileCollision) {
+		if progressCallback == nil {
+			return
+		}
+		completed := int(completedWork.Load())
 		var percentage float64
 		if totalDiscovered > 0 {
-			percentage = float64(completedWork) / float64(totalDiscovered) * 100.0
+			percentage = float64(completed) / float64(totalDiscovered) * 100.0
 		}
 
-		if progressCallback != nil {
-			progressCallback(ProgressInfo{
-				Completed:  completedWork,
-				Total:      totalDiscovered,
-				Current:    current,
-				Percentage: percentage,
-			})
-		}
+		progressMu.Lock()
+		defer progressMu.Unlock()
+		progressCallback(ProgressInfo{
+			Completed:  completed,
+			Total:      totalDiscovered,
+			Current:    current,
+			Percentage: percentage,
+			Errors:     errs,
+			Collisions: collisions,
+		})
 	}
 
-	// Create a channel for work distribution
-	workChan := make(chan string, len(allPackages))
-
-	// Create error channel to collect errors from workers
-	errChan := make(chan error, len(allPackages))
+	// Errors of every package that failed, joined into the returned error
+	var errs []error
+	var errsMu sync.Mutex
 
 	// Use throttled worker count instead of all CPUs
 	numWorkers := throttleConfig.MaxWorkers
 	if numWorkers > len(allPackages) {
 		numWorkers = len(allPackages)
 	}
+	if sinkWorkers <= 0 {
+		sinkWorkers = throttleConfig.MaxWorkers
+	}
 
 	// Log CPU throttling information if throttling is enabled
 	if throttleConfig.CPULimitPercent < 100 {
-		fmt.Printf("🔧 CPU throttling enabled: %d%% limit, %d workers (vs %d CPUs), %v delay\n", 
+		_, _ = fmt.Fprintf(os.Stderr, "🔧 CPU throttling enabled: %d%% limit, %d workers (vs %d CPUs), %v delay\n",
 			throttleConfig.CPULimitPercent, numWorkers, runtime.NumCPU(), throttleConfig.WorkerDelay)
 	}
 
-	// Start worker goroutines
+	batchSize := opts.loadBatchSize
+	if batchSize <= 0 {
+		batchSize = defaultLoadBatchSize
+	}
+
+	// The job channel holds one batch, so the next batch is loaded while the workers extract this one
+	jobChan := make(chan scanJob, batchSize)
+	resultChan := make(chan scanResult, numWorkers)
+
+	// Load: send all packages to the extract workers in batches, stopping once the scan is cancelled.
+	// Packages loaded by the go command are loaded with one go command run per batch.
+	go func() {
+		defer close(jobChan)
+		for start := 0; start < len(allPackages); start += batchSize {
+			batch := allPackages[start:min(start+batchSize, len(allPackages))]
+			for _, job := range loadScanJobs(ctx, batch, opts) {
+				select {
+				case <-ctx.Done():
+					return
+				case jobChan <- job:
+				}
+			}
+		}
+	}()
+
+	// Extract: scan the packages and pass them on to the sink workers
+	var extractWg sync.WaitGroup
 	for i := 0; i < numWorkers; i++ {
-		wg.Add(1)
+		extractWg.Add(1)
 		go func() {
-			defer wg.Done()
+			defer extractWg.Done()
+
+			for job := range jobChan {
+				currentPkgPath := job.pkgPath
+
+				// Packages still queued when the scan is cancelled are dropped
+				if ctx.Err() != nil {
+					return
+				}
 
-			for currentPkgPath := range workChan {
 				// Apply CPU throttling delay if configured
 				if throttleConfig.WorkerDelay > 0 {
 					time.Sleep(throttleConfig.WorkerDelay)
 				}
 
 				// Report progress before processing
-				reportProgress(currentPkgPath)
+				reportProgress(currentPkgPath, nil, nil)
+
+				// Packages whose sources are unchanged since the last run are not loaded again
+				if job.skipped {
+					completedWork.Add(1)
+					continue
+				}
 
 				// Scan the current package
-				packageInfo, err := ScanPackage(currentPkgPath, basePkgUrl)
+				packageInfo, err := scanPackageSafely(ctx, job, basePkgUrl, opts)
 				if err != nil {
-					errChan <- fmt.Errorf("failed to scan package %s: %w", currentPkgPath, err)
+					if opts.packageFailed != nil {
+						opts.packageFailed(currentPkgPath)
+					}
+					errsMu.Lock()
+					errs = append(errs, err)
+					errsMu.Unlock()
+					completedWork.Add(1)
 					continue
 				}
 
-				// Calculate the full package URL
-				var fullPkgUrl string
-				if currentPkgPath == "" {
-					fullPkgUrl = basePkgUrl
-				} else {
-					fullPkgUrl = fmt.Sprintf("%s/%s", basePkgUrl, currentPkgPath)
+				// Use the real import path of the package, falling back to the directory-based URL
+				fullPkgUrl := packageInfo.PkgPath
+				if fullPkgUrl == "" {
+					fullPkgUrl = joinPackageUrl(basePkgUrl, currentPkgPath)
+				}
+
+				// Packages loaded with errors are indexed with the declarations that parsed, and
+				// colliding index file names are written under disambiguated names
+				if len(packageInfo.Errors) > 0 || len(packageInfo.Collisions) > 0 {
+					reportProgress(fullPkgUrl, packageInfo.Errors, packageInfo.Collisions)
 				}
 
-				// Invoke callback for current package (protect with mutex for thread safety)
-				mu.Lock()
-				callback(packageInfo, fullPkgUrl)
-				completedWork++
-				mu.Unlock()
+				resultChan <- scanResult{packageInfo: packageInfo, pkgUrl: fullPkgUrl}
 
 				// Apply additional delay after processing if CPU throttling is aggressive
 				if throttleConfig.CPULimitPercent < 50 {
@@ -373,22 +669,33 @@ func ScanPackagesRecursively(pkgPath, basePkgUrl string, callback func(*PackageI
 			}
 		}()
 	}
-
-	// Send all packages to work channel
-	for _, pkg := range allPackages {
-		workChan <- pkg
+	go func() {
+		extractWg.Wait()
+		close(resultChan)
+	}()
+
+	// Sink: hand every scanned package to sink, including those scanned before a cancellation
+	var sinkWg sync.WaitGroup
+	for i := 0; i < sinkWorkers; i++ {
+		sinkWg.Add(1)
+		go func() {
+			defer sinkWg.Done()
+			for result := range resultChan {
+				sink(result.packageInfo, result.pkgUrl)
+				completedWork.Add(1)
+			}
+		}()
 	}
-	close(workChan)
+	sinkWg.Wait()
 
-	// Wait for all workers to complete
-	wg.Wait()
-	close(errChan)
+	// Packages abandoned on cancellation fail to load, the cancellation is what is reported
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 
-	// Check for any errors
-	for err := range errChan {
-		if err != nil {
-			return err
-		}
+	// Report every package that failed
+	if len(errs) > 0 {
+		return errors.Join(errs...)
 	}
 
 	// Report final 100% completion
@@ -404,14 +711,25 @@ func ScanPackagesRecursively(pkgPath, basePkgUrl string, callback func(*PackageI
 	return nil
 }
 
-// findSubPackages discovers all sub-packages under the given package path
-func findSubPackages(pkgPath string) []string {
-	var dirPath string
-	if pkgPath == "" {
-		dirPath = "."
-	} else {
-		dirPath = pkgPath
+// joinPackageUrl builds a package URL from the base package URL and a relative directory path
+func joinPackageUrl(basePkgUrl, pkgPath string) string {
+	pkgPath = strings.Trim(filepath.ToSlash(pkgPath), "/")
+	switch {
+	case pkgPath == "" || pkgPath == ".":
+		return basePkgUrl
+	case basePkgUrl == "":
+		return pkgPath
+	default:
+		return basePkgUrl + "/" + pkgPath
+	}
+}
+
+// findSubPackages discovers all sub-packages under the given package path, relative to root
+func findSubPackages(root, pkgPath string) []string {
+	if root == "" {
+		root = "."
 	}
+	dirPath := filepath.Join(root, filepath.FromSlash(pkgPath))
 
 	// Use recursive helper function
 	return findPackagesRecursively(dirPath, pkgPath)
@@ -433,6 +751,11 @@ func findPackagesRecursively(dirPath, pkgPath string) []string {
 
 		subDirPath := filepath.Join(dirPath, entry.Name())
 
+		// A directory with its own go.mod is a separate module, not a package of this one
+		if isModuleRoot(subDirPath) {
+			continue
+		}
+
 		// Construct sub-package path
 		var subPkgPath string
 		if pkgPath == "" {
@@ -452,6 +775,11 @@ func findPackagesRecursively(dirPath, pkgPath string) []string {
 	return subPackages
 }
 
+// isInternalPackage reports whether a package path has an "internal" element
+func isInternalPackage(pkgPath string) bool {
+	return slices.Contains(strings.Split(filepath.ToSlash(pkgPath), "/"), "internal")
+}
+
 // shouldSkipDirectory determines if a directory should be skipped during package scanning
 func shouldSkipDirectory(dirName string) bool {
 	skipDirs := map[string]bool{
@@ -472,3 +800,27 @@ func shouldSkipDirectory(dirName string) bool {
 
 // ScanPackage is an alias for ScanSinglePackage for backward compatibility
 var ScanPackage = ScanSinglePackage
+
+// scanPackageSafely scans the package of a job, reporting a failure to load it or a panic while extracting
+// its symbols as a PackageError. Packages the job did not preload are loaded on their own.
+func scanPackageSafely(ctx context.Context, job scanJob, basePkgUrl string, opts Options) (packageInfo *PackageInfo, err error) {
+	pkgPath := job.pkgPath
+	defer func() {
+		if r := recover(); r != nil {
+			packageInfo = nil
+			err = newPackageError(joinPackageUrl(basePkgUrl, pkgPath), PhaseExtract, fmt.Errorf("panic: %v", r))
+		}
+	}()
+	if job.loaded != nil {
+		packageInfo, err = checkLoadErrors(scanLoadedPackage(job.loaded, pkgPath, basePkgUrl, opts), opts)
+	} else {
+		packageInfo, err = scanPackage(ctx, pkgPath, basePkgUrl, opts)
+	}
+	if err != nil {
+		return nil, newPackageError(joinPackageUrl(basePkgUrl, pkgPath), PhaseLoad, err)
+	}
+	return packageInfo, nil
+}
+
+// scanPackage is the package scanner used by ScanPackagesRecursivelyWithOptions, stubbed in tests
+var scanPackage = ScanSinglePackageContext
@@ -1,10 +1,15 @@
 package pkg
 
 import (
+	"context"
 	"github.com/prashantv/gostub"
 	"github.com/spf13/afero"
+	"os"
 	"path/filepath"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -72,18 +77,24 @@ func TestScanPackage_PackageNameDifferentFromDirectoryName(t *testing.T) {
 	require.Len(t, result.Variables, 1, "Should find exactly one variable")
 	assert.Equal(t, "TestVariable", result.Variables[0].Name, "Should find TestVariable")
 
-	// Verify variable's package path - should use directory-based path for consistency
-	expectedPackagePath := "github.com/lonegunmanb/gophon/pkg/testharness/different_pkg"
+	// Verify variable's package path - should use the real import path, which is directory-based
+	expectedPackagePath := "github.com/lonegunmanb/gophon/pkg/testharness/mismatched_dir"
 	assert.Equal(t, expectedPackagePath, result.Variables[0].PackagePath(),
-		"Variable package path should use directory-based path for external accessibility")
+		"Variable package path should use the real import path for external accessibility")
+	assert.Equal(t, "different_pkg", result.Variables[0].PackageName())
+
+	// Both the directory and the declared package name are recorded
+	assert.Equal(t, expectedPackagePath, result.PkgPath)
+	assert.Equal(t, "different_pkg", result.Name)
+	assert.Equal(t, "mismatched_dir", filepath.Base(result.Dir))
 
 	// Verify one file is included (example.go)
 	require.Len(t, result.Files, 1, "Should find exactly one file")
 	assert.Equal(t, "example.go", filepath.Base(result.Files[0].FileName), "Should find example.go")
 
-	// Verify file's package path - should also use directory-based path
+	// Verify file's package path - should also use the real import path
 	assert.Equal(t, expectedPackagePath, result.Files[0].Package,
-		"File package path should use directory-based path for external accessibility")
+		"File package path should use the real import path for external accessibility")
 }
 
 func TestScanPackagesRecursively(t *testing.T) {
@@ -101,6 +112,18 @@ func TestScanPackagesRecursively(t *testing.T) {
 	assert.True(t, found)
 }
 
+func TestScanPackagesRecursivelyContext_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var scanned []string
+	err := ScanPackagesRecursivelyContext(ctx, "testharness", "github.com/lonegunmanb/gophon/pkg", Options{}, func(info *PackageInfo, s string) {
+		scanned = append(scanned, s)
+	}, nil)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Empty(t, scanned)
+}
+
 func TestFindPackagesRecursively_EmptyMiddleFolderShouldNotBeSkipped(t *testing.T) {
 	// Setup test filesystem with empty middle directories
 	files := map[string]string{
@@ -158,3 +181,87 @@ func setupMemoryFilesystem(fs afero.Fs, files map[string]string) {
 		}
 	}
 }
+
+func TestScanPackage_ExcludeDocComments(t *testing.T) {
+	result, err := ScanSinglePackageWithOptions("testharness", "github.com/lonegunmanb/gophon/pkg", Options{ExcludeDocComments: true})
+	require.NoError(t, err)
+
+	userType := findTypeByName(result.Types, "User")
+	require.NotNil(t, userType)
+	assert.True(t, strings.HasPrefix(userType.String(), "type User struct {"), "Doc comment should be excluded: %s", userType.String())
+
+	newServiceFunc := findFunctionByName(result.Functions, "NewService")
+	require.NotNil(t, newServiceFunc)
+	assert.True(t, strings.HasPrefix(newServiceFunc.String(), "func NewService("), "Doc comment should be excluded: %s", newServiceFunc.String())
+
+	isDebugModeVar := findVariableByName(result.Variables, "isDebugMode")
+	require.NotNil(t, isDebugModeVar)
+	assert.Equal(t, "\tisDebugMode bool = false", isDebugModeVar.String())
+}
+
+func TestScanPackage_MainPackageUsesRealImportPath(t *testing.T) {
+	// The module root holds package main; its import path is the module path, not <module>/main
+	result, err := ScanSinglePackage("..", "github.com/lonegunmanb/gophon")
+	require.NoError(t, err)
+
+	assert.Equal(t, "github.com/lonegunmanb/gophon", result.PkgPath)
+	assert.Equal(t, "main", result.Name)
+	for _, file := range result.Files {
+		assert.Equal(t, "github.com/lonegunmanb/gophon", file.Package)
+		assert.Equal(t, "main", file.PackageName())
+	}
+}
+
+func TestScanPackage_LoadErrors(t *testing.T) {
+	src := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(src, "go.mod"), []byte("module example.com/broken\n\ngo 1.23\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "broken.go"), []byte("package broken\n\nfunc Good() {}\n\nvar Bad int = \"not an int\"\n"), 0644))
+
+	// By default the package is indexed with its errors reported
+	result, err := ScanSinglePackageWithOptions("", "example.com/broken", Options{Root: src})
+	require.NoError(t, err)
+	require.Len(t, result.Errors, 1)
+	assert.Contains(t, result.Errors[0], "broken.go:5")
+	assert.NotNil(t, findFunctionByName(result.Functions, "Good"))
+
+	var reported []ProgressInfo
+	require.NoError(t, ScanPackagesRecursivelyWithOptions("", "example.com/broken", Options{Root: src}, func(*PackageInfo, string) {}, func(progress ProgressInfo) {
+		if len(progress.Errors) > 0 {
+			reported = append(reported, progress)
+		}
+	}))
+	require.Len(t, reported, 1)
+	assert.Equal(t, "example.com/broken", reported[0].Current)
+	assert.Equal(t, result.Errors, reported[0].Errors)
+
+	// Strict mode fails the package
+	_, err = ScanSinglePackageWithOptions("", "example.com/broken", Options{Root: src, Strict: true})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "package has 1 error(s)")
+}
+
+func TestScanPackage_SyntaxErrorsFromFs(t *testing.T) {
+	srcFs := afero.NewMemMapFs()
+	stubs := gostub.Stub(&sourceFs, srcFs)
+	defer stubs.Reset()
+	require.NoError(t, afero.WriteFile(srcFs, "/src/a/a.go", []byte("package a\n\nfunc Good() {}\n\nfunc Bad( {\n"), 0644))
+
+	result, err := ScanSinglePackageWithOptions("a", "example.com/fs", Options{Root: "/src"})
+	require.NoError(t, err)
+	assert.NotEmpty(t, result.Errors)
+	assert.NotNil(t, findFunctionByName(result.Functions, "Good"))
+}
+
+func TestScanPackagesRecursively_CallbackIsNotConcurrent(t *testing.T) {
+	var inFlight, maxInFlight atomic.Int32
+	err := ScanPackagesRecursively("testharness", "github.com/lonegunmanb/gophon/pkg", func(*PackageInfo, string) {
+		current := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		if current > maxInFlight.Load() {
+			maxInFlight.Store(current)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), maxInFlight.Load())
+}
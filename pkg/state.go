@@ -0,0 +1,267 @@
+package pkg
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/spf13/afero"
+)
+
+// stateFileName is the name of the file recording what was indexed into a destination folder
+const stateFileName = ".gophon-state.json"
+
+// gophonModulePath is the module path of gophon itself, used to find its version in build information
+const gophonModulePath = "github.com/lonegunmanb/gophon"
+
+// IndexState records the inputs of the last indexing run into a destination folder,
+// so packages whose sources have not changed can be skipped by the next run
+type IndexState struct {
+	Version  string                  `json:"version"`  // Gophon version that wrote the index files
+	Options  string                  `json:"options"`  // Fingerprint of the options affecting index content
+	Packages map[string]PackageState `json:"packages"` // Packages keyed by directory relative to the source root
+}
+
+// PackageState records the indexed state of a single package
+type PackageState struct {
+	Hash     string `json:"hash"`               // Hash of the package's Go source files
+	IndexDir string `json:"indexDir,omitempty"` // Directory of the package's index files relative to the destination, empty without symbols
+}
+
+// Version returns the version of gophon in use, read from the build information of the running binary.
+// Development builds are identified by their VCS revision when available.
+func Version() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "(devel)"
+	}
+
+	version := ""
+	if info.Main.Path == gophonModulePath {
+		version = info.Main.Version
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == gophonModulePath {
+			version = dep.Version
+		}
+	}
+	if version != "" && version != "(devel)" {
+		return version
+	}
+
+	version = "(devel)"
+	for _, setting := range info.Settings {
+		switch {
+		case setting.Key == "vcs.revision":
+			version += " " + setting.Value
+		case setting.Key == "vcs.modified" && setting.Value == "true":
+			version += "+dirty"
+		}
+	}
+	return version
+}
+
+// loadIndexState reads the state file of destFolder, returning an empty state when there is none.
+// An unreadable state file is ignored: the empty state is returned along with an error describing it.
+func loadIndexState(destFolder string) (*IndexState, error) {
+	state := &IndexState{Packages: make(map[string]PackageState)}
+	filePath := filepath.Join(destFolder, stateFileName)
+	content, err := afero.ReadFile(destFs, filePath)
+	if err != nil {
+		return state, nil
+	}
+	if err := json.Unmarshal(content, state); err != nil || state.Packages == nil {
+		return &IndexState{Packages: make(map[string]PackageState)}, fmt.Errorf("ignoring unreadable state file %s", filePath)
+	}
+	return state, nil
+}
+
+// saveIndexState writes the state file of destFolder
+func saveIndexState(destFolder string, state *IndexState) error {
+	content, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode state for %s: %w", destFolder, err)
+	}
+	if err := destFs.MkdirAll(destFolder, 0700); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", destFolder, err)
+	}
+	filePath := filepath.Join(destFolder, stateFileName)
+	if err := afero.WriteFile(destFs, filePath, append(content, '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to write state file %s: %w", filePath, err)
+	}
+	return nil
+}
+
+// optionsFingerprint describes the options and base package URL that affect the content of index files.
+// Syntax-only runs resolve less than full loads, so switching modes indexes everything again.
+func optionsFingerprint(basePkgUrl string, opts Options) string {
+	return fmt.Sprintf("base=%s;noDocs=%t;skipInternal=%t;revision=%s;strict=%t;syntaxOnly=%t", basePkgUrl, opts.ExcludeDocComments, opts.SkipInternal, opts.Revision, opts.Strict, opts.SyntaxOnly)
+}
+
+// hashPackageSources hashes the names and contents of the Go files in the package directory,
+// read through sourceFs. Files in subdirectories belong to other packages and are not included.
+// Imported packages are not hashed either: the value comment of a constant computed from another
+// package's constant stays stale until the package itself changes or the run is forced.
+func hashPackageSources(dir string) (string, error) {
+	entries, err := afero.ReadDir(sourceFs, dir)
+	if err != nil {
+		return "", err
+	}
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".go") {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+
+	hash := sha256.New()
+	for _, name := range names {
+		file, err := sourceFs.Open(filepath.Join(dir, name))
+		if err != nil {
+			return "", err
+		}
+		_, _ = fmt.Fprintf(hash, "%s\x00", name)
+		_, err = io.Copy(hash, file)
+		_ = file.Close()
+		if err != nil {
+			return "", err
+		}
+		_, _ = hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// incrementalRun tracks which packages of an indexing run are unchanged and the state to record afterwards
+type incrementalRun struct {
+	root       string
+	destFolder string
+	previous   *IndexState
+	next       *IndexState
+	skipped    []string // Index directories of skipped packages with symbols
+	mu         sync.Mutex
+}
+
+// newIncrementalRun prepares an incremental run over the subtree of root into destFolder from the previous
+// state recorded there. The previous state is discarded when the gophon version or the options changed, or
+// when force is set, so every package is indexed again. Otherwise the packages outside the subtree keep
+// their recorded state, as the run does not scan them.
+func newIncrementalRun(root, destFolder, basePkgUrl, subtree string, previous *IndexState, opts Options) *incrementalRun {
+	run := &incrementalRun{
+		root:       root,
+		destFolder: destFolder,
+		previous:   previous,
+		next: &IndexState{
+			Version:  Version(),
+			Options:  optionsFingerprint(basePkgUrl, opts),
+			Packages: make(map[string]PackageState),
+		},
+	}
+	if opts.Force || run.previous.Version != run.next.Version || run.previous.Options != run.next.Options {
+		run.previous.Packages = make(map[string]PackageState)
+	}
+	for key, state := range run.previous.Packages {
+		if !withinSubtree(key, subtree) {
+			run.next.Packages[key] = state
+		}
+	}
+	return run
+}
+
+// skip hashes the package at pkgPath and reports whether it is unchanged since the previous run
+func (r *incrementalRun) skip(pkgPath string) bool {
+	key := stateKey(pkgPath)
+	hash, err := hashPackageSources(filepath.Join(r.root, filepath.FromSlash(pkgPath)))
+	if err != nil {
+		return false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	previous, ok := r.previous.Packages[key]
+	if ok && previous.Hash == hash {
+		r.next.Packages[key] = previous
+		if previous.IndexDir != "" {
+			r.skipped = append(r.skipped, previous.IndexDir)
+		}
+		return true
+	}
+	r.next.Packages[key] = PackageState{Hash: hash}
+	return false
+}
+
+// indexed records where the index files of a freshly scanned package were written
+func (r *incrementalRun) indexed(pkgDir, indexDir string) {
+	// Packages parsed from sourceFs report the directory they were read from, relative to the working directory
+	if pkgDir != "" && !filepath.IsAbs(pkgDir) {
+		if absDir, err := filepath.Abs(pkgDir); err == nil {
+			pkgDir = absDir
+		}
+	}
+	rel, err := filepath.Rel(r.root, pkgDir)
+	if pkgDir == "" || err != nil {
+		return
+	}
+	key := stateKey(rel)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if state, ok := r.next.Packages[key]; ok {
+		state.IndexDir = indexDir
+		r.next.Packages[key] = state
+	}
+}
+
+// failed forgets the package at pkgPath, which failed to index, so the next run indexes it again
+func (r *incrementalRun) failed(pkgPath string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.next.Packages, stateKey(pkgPath))
+}
+
+// skippedManifests reads the manifests left in place by skipped packages
+func (r *incrementalRun) skippedManifests() []*Manifest {
+	var manifests []*Manifest
+	for _, indexDir := range r.skipped {
+		content, err := afero.ReadFile(destFs, filepath.Join(r.destFolder, filepath.FromSlash(indexDir), manifestFileName))
+		if err != nil {
+			continue
+		}
+		var manifest Manifest
+		if err := json.Unmarshal(content, &manifest); err != nil {
+			continue
+		}
+		manifests = append(manifests, &manifest)
+	}
+	return manifests
+}
+
+// save writes the state of this run. Nothing is written for a run that produced no index files
+// into a destination that does not exist.
+func (r *incrementalRun) save() error {
+	if exists, _ := afero.DirExists(destFs, r.destFolder); !exists {
+		return nil
+	}
+	return saveIndexState(r.destFolder, r.next)
+}
+
+// withinSubtree reports whether the slash-separated directory dir lies in subtree, "." being the whole tree
+func withinSubtree(dir, subtree string) bool {
+	return subtree == "." || dir == subtree || strings.HasPrefix(dir, subtree+"/")
+}
+
+// stateKey normalizes a package directory for use as a state key
+func stateKey(pkgPath string) string {
+	key := filepath.ToSlash(filepath.Clean(pkgPath))
+	if key == "" {
+		return "."
+	}
+	return key
+}
@@ -0,0 +1,175 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/prashantv/gostub"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndexSourceCode_Incremental(t *testing.T) {
+	srcFs := afero.NewMemMapFs()
+	stubs := gostub.Stub(&destFs, afero.NewMemMapFs())
+	stubs.Stub(&sourceFs, srcFs)
+	defer stubs.Reset()
+
+	require.NoError(t, afero.WriteFile(srcFs, "/src/go.mod", []byte("module example.com/inc\n"), 0644))
+	require.NoError(t, afero.WriteFile(srcFs, "/src/a/a.go", []byte("package a\n\nfunc A() {}\n"), 0644))
+	require.NoError(t, afero.WriteFile(srcFs, "/src/b/b.go", []byte("package b\n\nfunc B() {}\n"), 0644))
+
+	// Record which packages are actually loaded
+	var scanned []string
+	var mu sync.Mutex
+	stubs.Stub(&scanPackage, func(ctx context.Context, pkgPath, basePkgUrl string, opts Options) (*PackageInfo, error) {
+		mu.Lock()
+		scanned = append(scanned, pkgPath)
+		mu.Unlock()
+		return ScanSinglePackageContext(ctx, pkgPath, basePkgUrl, opts)
+	})
+	index := func(opts Options) []string {
+		scanned = nil
+		opts.Root = "/src"
+		require.NoError(t, IndexSourceCodeWithOptions("", "", "output", opts, nil))
+		sort.Strings(scanned)
+		return scanned
+	}
+
+	assert.Equal(t, []string{"", "a", "b"}, index(Options{}))
+
+	state, err := loadIndexState("output")
+	require.NoError(t, err)
+	assert.Equal(t, Version(), state.Version)
+	assert.Equal(t, "a", state.Packages["a"].IndexDir)
+	assert.NotEmpty(t, state.Packages["a"].Hash)
+
+	// Nothing changed, so nothing is loaded and the manifests still list every package
+	assert.Empty(t, index(Options{}))
+	assertRootManifestPackages(t, "example.com/inc/a", "example.com/inc/b")
+	exists, err := afero.Exists(destFs, "output/a/func.A.goindex")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	// Only the changed package is indexed again
+	require.NoError(t, afero.WriteFile(srcFs, "/src/b/b.go", []byte("package b\n\nfunc B2() {}\n"), 0644))
+	assert.Equal(t, []string{"b"}, index(Options{}))
+	exists, err = afero.Exists(destFs, "output/b/func.B2.goindex")
+	require.NoError(t, err)
+	assert.True(t, exists)
+	assertRootManifestPackages(t, "example.com/inc/a", "example.com/inc/b")
+
+	// Changed options, a different gophon version and Force index everything again
+	assert.Equal(t, []string{"", "a", "b"}, index(Options{ExcludeDocComments: true}))
+	assert.Equal(t, []string{"", "a", "b"}, index(Options{Force: true}))
+	// Switching between syntax-only and full loads too, as they resolve different details
+	assert.Equal(t, []string{"", "a", "b"}, index(Options{SyntaxOnly: true}))
+	assert.Equal(t, []string{"", "a", "b"}, index(Options{}))
+	state, err = loadIndexState("output")
+	require.NoError(t, err)
+	state.Version = "v0.0.1"
+	require.NoError(t, saveIndexState("output", state))
+	assert.Equal(t, []string{"", "a", "b"}, index(Options{}))
+}
+
+func TestIndexSourceCode_SubtreeRunKeepsOtherPackagesState(t *testing.T) {
+	srcFs := afero.NewMemMapFs()
+	stubs := gostub.Stub(&destFs, afero.NewMemMapFs())
+	stubs.Stub(&sourceFs, srcFs)
+	defer stubs.Reset()
+
+	require.NoError(t, afero.WriteFile(srcFs, "/src/go.mod", []byte("module example.com/inc\n"), 0644))
+	require.NoError(t, afero.WriteFile(srcFs, "/src/a/a.go", []byte("package a\n\nfunc A() {}\n"), 0644))
+	require.NoError(t, afero.WriteFile(srcFs, "/src/b/b.go", []byte("package b\n\nfunc B() {}\n"), 0644))
+
+	var scanned []string
+	var mu sync.Mutex
+	stubs.Stub(&scanPackage, func(ctx context.Context, pkgPath, basePkgUrl string, opts Options) (*PackageInfo, error) {
+		mu.Lock()
+		scanned = append(scanned, pkgPath)
+		mu.Unlock()
+		return ScanSinglePackageContext(ctx, pkgPath, basePkgUrl, opts)
+	})
+	opts := Options{Root: "/src"}
+	require.NoError(t, IndexSourceCodeWithOptions("", "", "output", opts, nil))
+
+	// Re-index the b subtree after changing it
+	require.NoError(t, afero.WriteFile(srcFs, "/src/b/b.go", []byte("package b\n\nfunc B2() {}\n"), 0644))
+	scanned = nil
+	require.NoError(t, IndexSourceCodeWithOptions("b", "", "output", opts, nil))
+	assert.Equal(t, []string{"b"}, scanned)
+
+	state, err := loadIndexState("output")
+	require.NoError(t, err)
+	assert.Contains(t, state.Packages, ".")
+	assert.Equal(t, "a", state.Packages["a"].IndexDir, "Packages outside the subtree keep their state")
+
+	// The next full run finds every package unchanged
+	scanned = nil
+	require.NoError(t, IndexSourceCodeWithOptions("", "", "output", opts, nil))
+	assert.Empty(t, scanned)
+}
+
+func TestIndexSourceCode_SubtreeRunMergesRootManifest(t *testing.T) {
+	srcFs := afero.NewMemMapFs()
+	stubs := gostub.Stub(&destFs, afero.NewMemMapFs())
+	stubs.Stub(&sourceFs, srcFs)
+	defer stubs.Reset()
+
+	require.NoError(t, afero.WriteFile(srcFs, "/src/go.mod", []byte("module example.com/inc\n"), 0644))
+	require.NoError(t, afero.WriteFile(srcFs, "/src/a/a.go", []byte("package a\n\nfunc A() {}\n"), 0644))
+	require.NoError(t, afero.WriteFile(srcFs, "/src/b/b.go", []byte("package b\n\nfunc B() {}\n"), 0644))
+	require.NoError(t, afero.WriteFile(srcFs, "/src/b/c/c.go", []byte("package c\n\nfunc C() {}\n"), 0644))
+	opts := Options{Root: "/src"}
+	require.NoError(t, IndexSourceCodeWithOptions("", "", "output", opts, nil))
+	assertRootManifestPackages(t, "example.com/inc/a", "example.com/inc/b", "example.com/inc/b/c")
+
+	// Packages outside the subtree stay linked, and a package deleted within it is dropped
+	require.NoError(t, srcFs.RemoveAll("/src/b/c"))
+	require.NoError(t, IndexSourceCodeWithOptions("b", "", "output", opts, nil))
+	assertRootManifestPackages(t, "example.com/inc/a", "example.com/inc/b")
+
+	// A subtree without symbols left is dropped too
+	require.NoError(t, afero.WriteFile(srcFs, "/src/b/b.go", []byte("package b\n"), 0644))
+	require.NoError(t, IndexSourceCodeWithOptions("b", "", "output", opts, nil))
+	assertRootManifestPackages(t, "example.com/inc/a")
+}
+
+func TestIndexSourceCode_UnreadableStateFile(t *testing.T) {
+	stub := gostub.Stub(&destFs, afero.NewMemMapFs())
+	defer stub.Reset()
+	require.NoError(t, afero.WriteFile(destFs, "output/"+stateFileName, []byte("not json"), 0600))
+
+	var warnings []string
+	require.NoError(t, IndexSourceCode("testharness/sub_pkg", "github.com/lonegunmanb/gophon/pkg", "output", func(progress ProgressInfo) {
+		warnings = append(warnings, progress.Warnings...)
+	}))
+	assert.Equal(t, []string{"ignoring unreadable state file " + filepath.Join("output", stateFileName)}, warnings)
+
+	// The state file is rewritten
+	_, err := loadIndexState("output")
+	assert.NoError(t, err)
+}
+
+// assertRootManifestPackages checks the packages listed by the root manifest in output
+func assertRootManifestPackages(t *testing.T, expected ...string) {
+	assert.Equal(t, expected, rootManifestPackages(t))
+}
+
+// rootManifestPackages returns the packages listed by the root manifest in output
+func rootManifestPackages(t *testing.T) []string {
+	content, err := afero.ReadFile(destFs, "output/manifest.json")
+	require.NoError(t, err)
+	var rootManifest Manifest
+	require.NoError(t, json.Unmarshal(content, &rootManifest))
+	var packages []string
+	for _, manifest := range rootManifest.Packages {
+		packages = append(packages, manifest.Package)
+	}
+	return packages
+}
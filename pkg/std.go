@@ -0,0 +1,73 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path"
+	"path/filepath"
+)
+
+// stdModulePath is the module path of the standard library, declared in $GOROOT/src/go.mod
+const stdModulePath = "std"
+
+// Toolchain describes the active Go toolchain
+type Toolchain struct {
+	GoRoot  string `json:"GOROOT"`    // Root of the Go installation
+	Version string `json:"GOVERSION"` // Toolchain version, e.g. go1.24.5
+}
+
+// FindToolchain asks the go command for the GOROOT and version of the active toolchain
+func FindToolchain() (Toolchain, error) {
+	cmd := exec.Command("go", "env", "-json", "GOROOT", "GOVERSION")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return Toolchain{}, fmt.Errorf("failed to query the go toolchain: %w: %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+
+	var toolchain Toolchain
+	if err := json.Unmarshal(output, &toolchain); err != nil {
+		return Toolchain{}, fmt.Errorf("failed to decode go env output: %w", err)
+	}
+	if toolchain.GoRoot == "" || toolchain.Version == "" {
+		return Toolchain{}, fmt.Errorf("go env reported no GOROOT or GOVERSION")
+	}
+	return toolchain, nil
+}
+
+// IndexStandardLibrary indexes the standard library of the toolchain from $GOROOT/src into a versioned
+// subtree of destFolder, such as std@go1.24.5. Internal, vendor and testdata packages are skipped, and so
+// is the cmd module. pkgPath limits indexing to a subtree such as "net"; an empty pkgPath indexes everything.
+// The root manifest of destFolder links the standard library's manifest. Package failures are handled
+// as by IndexSourceCodeContext, reported in the errors.json of the std@<version> subtree, and so is
+// cancellation through ctx.
+func IndexStandardLibrary(ctx context.Context, toolchain Toolchain, pkgPath, destFolder string, opts Options, progressCallback func(ProgressInfo)) error {
+	srcDir := filepath.Join(toolchain.GoRoot, "src")
+	stdOpts := opts
+	stdOpts.Root = srcDir
+	stdOpts.SkipInternal = true
+
+	stdDir := stdModulePath + "@" + toolchain.Version
+	stdDestFolder := filepath.Join(destFolder, stdDir)
+
+	manifest, err := indexModule(ctx, pkgPath, "", stdDestFolder, srcDir, stdOpts, progressCallback)
+	err = reportFailures(stdDestFolder, err, opts)
+	// In keep-going mode, the packages indexed despite failures are linked too
+	if manifest == nil {
+		return err
+	}
+	if saveErr := saveRootManifest(stdDestFolder, stateKey(pkgPath), manifest); saveErr != nil {
+		return errors.Join(err, saveErr)
+	}
+	return errors.Join(err, linkModules(destFolder, []ManifestModule{{
+		Path:     stdModulePath,
+		Version:  toolchain.Version,
+		Dir:      stdDir,
+		Manifest: path.Join(stdDir, manifestFileName),
+	}}))
+}
@@ -0,0 +1,58 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/prashantv/gostub"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndexStandardLibrary(t *testing.T) {
+	stub := gostub.Stub(&destFs, afero.NewMemMapFs())
+	defer stub.Reset()
+
+	toolchain, err := FindToolchain()
+	require.NoError(t, err)
+	assert.Regexp(t, `^go1\.`, toolchain.Version)
+
+	require.NoError(t, IndexStandardLibrary(context.Background(), toolchain, "container", "output", Options{}, nil))
+
+	stdDir := "output/std@" + toolchain.Version
+	content, err := afero.ReadFile(destFs, stdDir+"/container/list/type.List.goindex")
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "// Import path: container/list\n")
+	assert.Contains(t, string(content), "package list\n")
+
+	content, err = afero.ReadFile(destFs, "output/manifest.json")
+	require.NoError(t, err)
+	var rootManifest Manifest
+	require.NoError(t, json.Unmarshal(content, &rootManifest))
+	require.Len(t, rootManifest.Modules, 1)
+	assert.Equal(t, "std", rootManifest.Modules[0].Path)
+	assert.Equal(t, toolchain.Version, rootManifest.Modules[0].Version)
+	assert.Equal(t, "std@"+toolchain.Version+"/manifest.json", rootManifest.Modules[0].Manifest)
+}
+
+func TestScanPackagesRecursively_SkipInternal(t *testing.T) {
+	toolchain, err := FindToolchain()
+	require.NoError(t, err)
+
+	var scanned []string
+	err = ScanPackagesRecursivelyWithOptions("image", "", Options{Root: toolchain.GoRoot + "/src", SkipInternal: true},
+		func(pkgInfo *PackageInfo, pkgUrl string) {
+			scanned = append(scanned, pkgUrl)
+		}, nil)
+	require.NoError(t, err)
+
+	assert.Contains(t, scanned, "image/color")
+	assert.NotContains(t, scanned, "image/internal/imageutil")
+	for _, pkgUrl := range scanned {
+		assert.False(t, isInternalPackage(pkgUrl), "Internal package %s should be skipped", pkgUrl)
+	}
+	assert.True(t, isInternalPackage("crypto/internal/fips140"))
+	assert.False(t, isInternalPackage("crypto/internals"))
+}
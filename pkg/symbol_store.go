@@ -0,0 +1,348 @@
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/spf13/afero"
+)
+
+// SymbolStore holds generated index content in memory so it can be served to AI agents.
+// Packages are keyed by their full package URL, symbols by their index file name.
+type SymbolStore struct {
+	basePkgUrl string
+	packages   map[string]map[string]string
+	indexFiles map[string]map[string][]string // Index files of each package's symbols, keyed by their predictable name
+	mu         sync.RWMutex
+}
+
+// SymbolMatch describes a symbol found by SymbolStore.Search
+type SymbolMatch struct {
+	Package   string // Full package URL
+	IndexFile string // Index file name, e.g. type.User.goindex
+}
+
+// NewSymbolStore creates an empty SymbolStore for packages under basePkgUrl
+func NewSymbolStore(basePkgUrl string) *SymbolStore {
+	return &SymbolStore{
+		basePkgUrl: basePkgUrl,
+		packages:   make(map[string]map[string]string),
+		indexFiles: make(map[string]map[string][]string),
+	}
+}
+
+// LoadSymbolStore reads an index tree produced by IndexSourceCode from the destination filesystem.
+// Each directory containing .goindex files becomes a package whose URL is the import path recorded in
+// the index files or, for files without one, basePkgUrl joined with the directory path relative to indexFolder.
+// Package manifests map symbols to their index files, so symbols with disambiguated file names are found too.
+func LoadSymbolStore(indexFolder, basePkgUrl string) (*SymbolStore, error) {
+	store := NewSymbolStore(basePkgUrl)
+	err := afero.Walk(destFs, indexFolder, func(path string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && info.Name() == manifestFileName {
+			return store.addManifest(path)
+		}
+		if info.IsDir() || !strings.HasSuffix(path, ".goindex") {
+			return nil
+		}
+
+		relDir, err := filepath.Rel(indexFolder, filepath.Dir(path))
+		if err != nil {
+			return err
+		}
+
+		content, err := afero.ReadFile(destFs, path)
+		if err != nil {
+			return err
+		}
+
+		// Index files record their import path, which also covers modules nested in a workspace
+		pkgUrl := indexImportPath(string(content))
+		if pkgUrl == "" {
+			pkgUrl = store.packageUrl(filepath.ToSlash(relDir))
+		}
+		store.put(pkgUrl, filepath.Base(path), string(content))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load index folder %s: %w", indexFolder, err)
+	}
+	return store, nil
+}
+
+// BuildSymbolStore indexes packages on the fly through ScanPackagesRecursively,
+// without writing any index file to disk. In a workspace every module is scanned under its own module path.
+func BuildSymbolStore(pkgPath, basePkgUrl string, opts Options, progressCallback func(ProgressInfo)) (*SymbolStore, error) {
+	modules := []ModuleInfo{{Dir: "."}}
+	if pkgPath == "" {
+		found, err := FindModules(opts.Root)
+		if err != nil {
+			return nil, err
+		}
+		if isWorkspace(opts.Root, found) {
+			modules = found
+		}
+	}
+
+	// An explicit base package URL names the root; otherwise its import path is detected.
+	// Relative package paths are resolved against it.
+	if len(modules) > 0 && modules[0].Dir == "." {
+		if basePkgUrl != "" {
+			modules[0].Path = basePkgUrl
+		}
+		if modules[0].Path == "" {
+			detected, err := resolveBasePkgUrl("", opts)
+			if err != nil {
+				return nil, err
+			}
+			modules[0].Path = detected
+		}
+		basePkgUrl = modules[0].Path
+	}
+
+	store := NewSymbolStore(basePkgUrl)
+	callback := func(pkgInfo *PackageInfo, pkgUrl string) {
+		store.putSymbols(pkgUrl, newPackageManifest(pkgInfo, pkgUrl, ".", "").Symbols)
+		addSymbols(store, pkgUrl, pkgInfo.Constants)
+		addSymbols(store, pkgUrl, pkgInfo.Variables)
+		addSymbols(store, pkgUrl, pkgInfo.Types)
+		addSymbols(store, pkgUrl, pkgInfo.Functions)
+	}
+
+	for _, module := range modules {
+		moduleOpts := opts
+		moduleOpts.Root = module.sourceDir(opts.Root)
+		if err := ScanPackagesRecursivelyWithOptions(pkgPath, module.Path, moduleOpts, callback, progressCallback); err != nil {
+			return nil, err
+		}
+	}
+	return store, nil
+}
+
+// addSymbols renders the index content of every symbol and stores it under pkgUrl
+func addSymbols[T IndexableSymbol](s *SymbolStore, pkgUrl string, symbols []T) {
+	for _, symbol := range symbols {
+		s.put(pkgUrl, symbol.IndexFileName(), generateIndexContent(symbol, ""))
+	}
+}
+
+// put stores the content of a single index file
+func (s *SymbolStore) put(pkgUrl, indexFile, content string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	symbols, ok := s.packages[pkgUrl]
+	if !ok {
+		symbols = make(map[string]string)
+		s.packages[pkgUrl] = symbols
+	}
+	symbols[indexFile] = content
+}
+
+// addManifest records the index files of the symbols listed by the package manifest at filePath
+func (s *SymbolStore) addManifest(filePath string) error {
+	content, err := afero.ReadFile(destFs, filePath)
+	if err != nil {
+		return err
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(content, &manifest); err != nil {
+		return fmt.Errorf("failed to decode manifest %s: %w", filePath, err)
+	}
+	if manifest.Package != "" {
+		s.putSymbols(manifest.Package, manifest.Symbols)
+	}
+	return nil
+}
+
+// putSymbols records the index files of a package's symbols under their predictable names
+func (s *SymbolStore) putSymbols(pkgUrl string, symbols []ManifestSymbol) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	indexFiles, ok := s.indexFiles[pkgUrl]
+	if !ok {
+		indexFiles = make(map[string][]string)
+		s.indexFiles[pkgUrl] = indexFiles
+	}
+	for _, symbol := range symbols {
+		name := symbol.Name
+		if symbol.Kind == "method" {
+			name = strings.TrimPrefix(symbol.Receiver, "*") + "." + name
+		}
+		predictable, err := symbolIndexFileName(symbol.Kind, name)
+		if err != nil {
+			continue
+		}
+		indexFile := path.Base(symbol.IndexFile)
+		if !slices.Contains(indexFiles[predictable], indexFile) {
+			indexFiles[predictable] = append(indexFiles[predictable], indexFile)
+		}
+	}
+}
+
+// packageUrl joins a relative package path with the base package URL
+func (s *SymbolStore) packageUrl(relPath string) string {
+	return joinPackageUrl(s.basePkgUrl, relPath)
+}
+
+// resolvePackage accepts either a full package URL or a path relative to the base package URL
+func (s *SymbolStore) resolvePackage(pkg string) (map[string]string, string, bool) {
+	pkg = strings.Trim(pkg, "/")
+	for _, candidate := range []string{pkg, s.packageUrl(pkg)} {
+		if symbols, ok := s.packages[candidate]; ok {
+			return symbols, candidate, true
+		}
+	}
+	return nil, "", false
+}
+
+// Packages returns the sorted URLs of all packages in the store
+func (s *SymbolStore) Packages() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []string
+	for pkgUrl := range s.packages {
+		result = append(result, pkgUrl)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// ListPackage returns the sorted index file names of every symbol in the package
+func (s *SymbolStore) ListPackage(pkg string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	symbols, _, ok := s.resolvePackage(pkg)
+	if !ok {
+		return nil, fmt.Errorf("package %s not found", pkg)
+	}
+
+	var result []string
+	for indexFile := range symbols {
+		result = append(result, indexFile)
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// GetSymbol returns the index content of a symbol.
+// kind is one of func, method, type, var or const; for methods name is <ReceiverType>.<MethodName>.
+// Symbols sharing the name, such as several init functions, are written to disambiguated index files;
+// the content of every one of them is returned, each preceded by an "// Index file:" line.
+func (s *SymbolStore) GetSymbol(pkg, kind, name string) (string, error) {
+	indexFile, err := symbolIndexFileName(kind, name)
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	symbols, pkgUrl, ok := s.resolvePackage(pkg)
+	if !ok {
+		return "", fmt.Errorf("package %s not found", pkg)
+	}
+
+	// Index files without a manifest, or a disambiguated file name asked for directly, are looked up as is
+	candidates := s.indexFiles[pkgUrl][indexFile]
+	if len(candidates) == 0 {
+		candidates = []string{indexFile}
+	}
+	var matches []string
+	for _, candidate := range candidates {
+		if _, ok := symbols[candidate]; ok {
+			matches = append(matches, candidate)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("symbol %s not found in package %s", indexFile, pkgUrl)
+	case 1:
+		return symbols[matches[0]], nil
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		// The symbol keeping the predictable name comes first
+		if (matches[i] == indexFile) != (matches[j] == indexFile) {
+			return matches[i] == indexFile
+		}
+		return matches[i] < matches[j]
+	})
+	var sb strings.Builder
+	for i, match := range matches {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		fmt.Fprintf(&sb, "// Index file: %s\n%s", match, symbols[match])
+	}
+	return sb.String(), nil
+}
+
+// Search returns symbols whose name contains query (case-insensitive), sorted by package and file name
+func (s *SymbolStore) Search(query string) []SymbolMatch {
+	query = strings.ToLower(query)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var matches []SymbolMatch
+	for pkgUrl, symbols := range s.packages {
+		for indexFile := range symbols {
+			if strings.Contains(strings.ToLower(symbolName(indexFile)), query) {
+				matches = append(matches, SymbolMatch{Package: pkgUrl, IndexFile: indexFile})
+			}
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Package != matches[j].Package {
+			return matches[i].Package < matches[j].Package
+		}
+		return matches[i].IndexFile < matches[j].IndexFile
+	})
+	return matches
+}
+
+// symbolIndexFileName maps a symbol kind and name to the index file name produced by IndexFileName
+func symbolIndexFileName(kind, name string) (string, error) {
+	name = strings.TrimPrefix(name, "*")
+	switch kind {
+	case "func", "type", "var", "method":
+		return fmt.Sprintf("%s.%s.goindex", kind, name), nil
+	case "const":
+		// Constants share the var prefix with variables
+		return fmt.Sprintf("var.%s.goindex", name), nil
+	default:
+		return "", fmt.Errorf("unknown symbol kind %q, expected one of func, method, type, var, const", kind)
+	}
+}
+
+// symbolName strips the kind prefix and .goindex suffix from an index file name
+func symbolName(indexFile string) string {
+	name := strings.TrimSuffix(indexFile, ".goindex")
+	if _, rest, ok := strings.Cut(name, "."); ok {
+		return rest
+	}
+	return name
+}
+
+// indexImportPath returns the import path recorded in the header of an index file, or an empty string
+func indexImportPath(content string) string {
+	for _, line := range strings.SplitN(content, "\n", 3) {
+		if importPath, ok := strings.CutPrefix(line, indexImportPathPrefix); ok {
+			return strings.TrimSpace(importPath)
+		}
+	}
+	return ""
+}
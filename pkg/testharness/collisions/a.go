@@ -0,0 +1,8 @@
+// Package collisions provides test subjects whose predictable index file names collide.
+package collisions
+
+var registry []string
+
+func init() {
+	registry = append(registry, "a")
+}
@@ -0,0 +1,5 @@
+package collisions
+
+func init() {
+	registry = append(registry, "b")
+}
@@ -0,0 +1,45 @@
+// Package enums provides test subjects for enum-style constant groups.
+package enums
+
+import "time"
+
+// Kind enumerates the kinds of things.
+type Kind int
+
+// Kinds of things, testing iota with implicit repetition.
+const (
+	KindA Kind = iota
+	KindB
+	KindC
+)
+
+// Sizes testing implicit repetition of an iota expression.
+const (
+	KB = 1 << (10 * (iota + 1))
+	MB
+)
+
+// Timeouts testing a group without iota or implicit repetition.
+const (
+	ShortTimeout = time.Second
+	LongTimeout  = time.Minute
+)
+
+// Banners testing a long string value that must not be shortened.
+const (
+	BannerA = "This banner is long enough that a shortened form of its value would be truncated, " + string(rune('A'+iota))
+	BannerB
+)
+
+// Moles testing a large untyped float value that must not be rounded.
+const (
+	OneMole = 6.02214076e23 * (iota + 1)
+	TwoMoles
+)
+
+// Flags testing a trailing comment that spans several lines.
+const (
+	FlagBindNow = 0x8 /* Resolve every symbol when the object is loaded,
+	   instead of on first use. */
+	FlagOrigin = 0x1
+)
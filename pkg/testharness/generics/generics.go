@@ -0,0 +1,36 @@
+// Package generics provides test subjects for methods declared on generic types.
+package generics
+
+// List is a generic list with a single type parameter.
+type List[T any] struct {
+	items []T
+}
+
+// Push appends a value to the list.
+// Testing pointer receiver with a single type parameter.
+func (l *List[T]) Push(v T) {
+	l.items = append(l.items, v)
+}
+
+// Len returns the number of items in the list.
+// Testing value receiver with a single type parameter.
+func (l List[T]) Len() int {
+	return len(l.items)
+}
+
+// Pair is a generic pair with multiple type parameters.
+type Pair[K comparable, V any] struct {
+	Key   K
+	Value V
+}
+
+// Set replaces the value of the pair.
+// Testing receiver with multiple type parameters.
+func (p *Pair[K, V]) Set(v V) {
+	p.Value = v
+}
+
+// Push is a top-level function sharing its name with a generic method.
+func Push[T any](l *List[T], v T) {
+	l.Push(v)
+}
@@ -0,0 +1,6 @@
+package lib
+
+// Hello returns a greeting from a package of the nested module
+func Hello() string {
+	return "hello"
+}
@@ -0,0 +1,4 @@
+package nested
+
+// Version is the version of the nested module
+const Version = "1.0.0"
@@ -3,17 +3,81 @@ package pkg
 import (
 	"fmt"
 	"go/ast"
+	"sort"
+	"strings"
 )
 
 // TypeInfo contains information about type declarations
 type TypeInfo struct {
 	*Range
 	*ast.GenDecl
-	Name string
+	Spec          *ast.TypeSpec
+	Name          string
+	Methods       []*FunctionInfo // Methods declared on this type in the package, with pointer or value receivers
+	indexFileName string          // disambiguated index file name, set when the predictable name collides
+}
+
+// TypeParams returns the type parameter names of a generic type, or nil for non-generic types
+func (t *TypeInfo) TypeParams() []string {
+	if t.Spec == nil || t.Spec.TypeParams == nil {
+		return nil
+	}
+	var typeParams []string
+	for _, field := range t.Spec.TypeParams.List {
+		for _, name := range field.Names {
+			typeParams = append(typeParams, name.Name)
+		}
+	}
+	return typeParams
 }
 
 // IndexFileName generates a predictable index file name for this type
 // Returns a file name in the format: type.<TypeName>.goindex
 func (t *TypeInfo) IndexFileName() string {
+	if t.indexFileName != "" {
+		return t.indexFileName
+	}
 	return fmt.Sprintf("type.%s.goindex", t.Name)
 }
+
+// Imports returns only the import declarations referenced by this type's declaration
+func (t *TypeInfo) Imports() string {
+	if t.Range == nil || t.FileInfo == nil {
+		return ""
+	}
+	if t.Spec == nil {
+		return t.FileInfo.Imports()
+	}
+	return t.FileInfo.importsFor(t.Spec)
+}
+
+// enclosingDecl returns the declaration containing this type and the range of its snippet
+func (t *TypeInfo) enclosingDecl() (*ast.GenDecl, *Range) {
+	return t.GenDecl, t.Range
+}
+
+// MethodSet returns a comment section listing the signature of every method declared on this type,
+// each followed by the name of the method's own index file. It returns an empty string for types without methods.
+func (t *TypeInfo) MethodSet() string {
+	if len(t.Methods) == 0 {
+		return ""
+	}
+
+	methods := make([]*FunctionInfo, len(t.Methods))
+	copy(methods, t.Methods)
+	sort.SliceStable(methods, func(i, j int) bool {
+		return methods[i].Name < methods[j].Name
+	})
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "// Methods of %s:\n", t.Name)
+	for _, method := range methods {
+		fmt.Fprintf(&sb, "//   %s // %s\n", method.Signature(), method.IndexFileName())
+	}
+	return sb.String()
+}
+
+// setIndexFileName overrides the predictable index file name after a collision
+func (t *TypeInfo) setIndexFileName(name string) {
+	t.indexFileName = name
+}
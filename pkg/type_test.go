@@ -4,6 +4,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -49,7 +50,9 @@ func TestScanPackage_ExtractsTypes(t *testing.T) {
 	assert.True(t, filepath.IsAbs(userType.FileName), "FileName should be absolute path")
 
 	// Assert String() method returns the exact source code from subjects.go for User struct
-	expectedUserSource := `type User struct {
+	expectedUserSource := `// User represents a simple user entity.
+// Testing struct field extraction with types and tags.
+type User struct {
 	ID    int64  ` + "`json:\"id\" db:\"user_id\"`" + `
 	Name  string ` + "`json:\"name\" db:\"full_name\"`" + `
 	Email string ` + "`json:\"email\" db:\"email\"`" + `
@@ -64,7 +67,9 @@ func TestScanPackage_ExtractsTypes(t *testing.T) {
 	assert.True(t, filepath.IsAbs(userServiceType.FileName), "FileName should be absolute path")
 
 	// Assert String() method returns the exact source code from subjects.go for UserService interface
-	expectedUserServiceSource := `type UserService interface {
+	expectedUserServiceSource := `// UserService defines operations for user management.
+// Testing interface method signature extraction.
+type UserService interface {
 	Create(ctx context.Context, user *User) error
 	GetByID(ctx context.Context, id int64) (*User, error)
 	Update(ctx context.Context, user *User) error
@@ -79,7 +84,8 @@ func TestScanPackage_ExtractsTypes(t *testing.T) {
 	assert.True(t, filepath.IsAbs(serviceType.FileName), "FileName should be absolute path")
 
 	// Assert String() method returns the exact source code from subjects.go for Service struct
-	expectedServiceSource := `type Service struct {
+	expectedServiceSource := `// Service implements user business logic.
+type Service struct {
 	userService UserService
 }`
 	assert.Equal(t, expectedServiceSource, serviceType.String(), "Service String() should return exact source code")
@@ -167,3 +173,23 @@ func TestTypeInfo_IndexFileName(t *testing.T) {
 		})
 	}
 }
+
+func TestScanPackage_TypeMethodSet(t *testing.T) {
+	packageResult := scanHarnessPackage(t)
+
+	serviceType := findTypeByName(packageResult.Types, "Service")
+	require.NotNil(t, serviceType)
+	require.Len(t, serviceType.Methods, 2)
+
+	expectedMethodSet := `// Methods of Service:
+//   func (s *Service) CreateUser(ctx context.Context, name, email string) (*User, error) // method.Service.CreateUser.goindex
+//   func (s *Service) GetUser(ctx context.Context, id int64) (*User, error) // method.Service.GetUser.goindex
+`
+	assert.Equal(t, expectedMethodSet, serviceType.MethodSet())
+	assert.True(t, strings.HasSuffix(generateIndexContent(serviceType, ""), "}\n\n"+expectedMethodSet))
+
+	userType := findTypeByName(packageResult.Types, "User")
+	require.NotNil(t, userType)
+	assert.Empty(t, userType.Methods)
+	assert.Equal(t, "", userType.MethodSet())
+}
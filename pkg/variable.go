@@ -9,11 +9,53 @@ import (
 type VariableInfo struct {
 	*Range
 	*ast.GenDecl
-	Name string
+	Spec          *ast.ValueSpec
+	Name          string
+	indexFileName string // disambiguated index file name, set when the predictable name collides
 }
 
 // IndexFileName generates a predictable index file name for this variable
 // Returns a file name in the format: var.<VariableName>.goindex
 func (v *VariableInfo) IndexFileName() string {
+	if v.indexFileName != "" {
+		return v.indexFileName
+	}
 	return fmt.Sprintf("var.%s.goindex", v.Name)
 }
+
+// Imports returns only the import declarations referenced by this variable's declaration
+func (v *VariableInfo) Imports() string {
+	return valueSpecImports(v.Range, v.GenDecl, v.Spec)
+}
+
+// valueSpecImports returns the imports referenced by a const or var spec, including
+// directives such as //go:embed found in the doc comment of its declaration
+func valueSpecImports(r *Range, genDecl *ast.GenDecl, spec *ast.ValueSpec) string {
+	if r == nil || r.FileInfo == nil {
+		return ""
+	}
+	if spec == nil {
+		return r.FileInfo.Imports()
+	}
+
+	// A snippet covering the whole declaration needs the imports of every spec in it
+	if genDecl != nil && r.covers(genDecl) {
+		return r.FileInfo.importsFor(genDecl)
+	}
+
+	nodes := []ast.Node{spec}
+	if genDecl != nil && genDecl.Doc != nil {
+		nodes = append(nodes, genDecl.Doc)
+	}
+	return r.FileInfo.importsFor(nodes...)
+}
+
+// enclosingDecl returns the declaration containing this variable and the range of its snippet
+func (v *VariableInfo) enclosingDecl() (*ast.GenDecl, *Range) {
+	return v.GenDecl, v.Range
+}
+
+// setIndexFileName overrides the predictable index file name after a collision
+func (v *VariableInfo) setIndexFileName(name string) {
+	v.indexFileName = name
+}
@@ -38,7 +38,7 @@ func TestScanPackage_ExtractsVariables(t *testing.T) {
 	assert.NotNil(t, isDebugModeVar.GenDecl)
 
 	// Assert String() method returns the exact source code from subjects.go
-	assert.Equal(t, "\tisDebugMode bool = false", isDebugModeVar.String(), "IsDebugMode String() should return exact source code line")
+	assert.Equal(t, "\t//internal variable for testing\n\tisDebugMode bool = false", isDebugModeVar.String(), "IsDebugMode String() should return exact source code line")
 }
 
 func TestScanPackage_SkipsBlankIdentifierVariables(t *testing.T) {
@@ -0,0 +1,49 @@
+package pkg
+
+import (
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"sort"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+// VerificationFailure describes an index file that is not valid Go source
+type VerificationFailure struct {
+	File string // Path of the index file
+	Err  error  // Parse error reported by go/parser
+}
+
+// VerifyIndexFiles parses every .goindex file under indexFolder in the destination filesystem
+// with go/parser and returns the files that do not parse, sorted by path.
+func VerifyIndexFiles(indexFolder string) ([]VerificationFailure, error) {
+	var failures []VerificationFailure
+	fset := token.NewFileSet()
+	err := afero.Walk(destFs, indexFolder, func(path string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !strings.HasSuffix(path, ".goindex") {
+			return nil
+		}
+
+		content, err := afero.ReadFile(destFs, path)
+		if err != nil {
+			return err
+		}
+		if _, err := parser.ParseFile(fset, path, content, parser.AllErrors|parser.ParseComments); err != nil {
+			failures = append(failures, VerificationFailure{File: path, Err: err})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(failures, func(i, j int) bool {
+		return failures[i].File < failures[j].File
+	})
+	return failures, nil
+}
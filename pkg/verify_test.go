@@ -0,0 +1,59 @@
+package pkg
+
+import (
+	"testing"
+
+	"github.com/prashantv/gostub"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyIndexFiles_GeneratedIndexesParse(t *testing.T) {
+	stub := gostub.Stub(&destFs, afero.NewMemMapFs())
+	defer stub.Reset()
+
+	require.NoError(t, IndexSourceCodeWithoutProgress("testharness", "github.com/lonegunmanb/gophon/pkg", "output"))
+
+	failures, err := VerifyIndexFiles("output")
+	require.NoError(t, err)
+	assert.Empty(t, failures)
+
+	content, err := afero.ReadFile(destFs, "output/testharness/var.DefaultTimeout.goindex")
+	require.NoError(t, err)
+	assert.Equal(t, `// Code generated by gophon. DO NOT EDIT.
+// Import path: github.com/lonegunmanb/gophon/pkg/testharness
+
+package testharness
+
+import "time"
+
+const (
+	DefaultTimeout = 30 * time.Second
+)
+`, string(content))
+
+	// A trailing comment spanning several lines is kept whole
+	content, err = afero.ReadFile(destFs, "output/testharness/enums/var.FlagBindNow.goindex")
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "\tFlagBindNow = 0x8 /* Resolve every symbol when the object is loaded,\n\t   instead of on first use. */\n")
+
+	content, err = afero.ReadFile(destFs, "output/testharness/mismatched_dir/var.TestVariable.goindex")
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "\npackage different_pkg\n")
+}
+
+func TestVerifyIndexFiles_ReportsInvalidFiles(t *testing.T) {
+	stub := gostub.Stub(&destFs, afero.NewMemMapFs())
+	defer stub.Reset()
+
+	require.NoError(t, afero.WriteFile(destFs, "output/pkg/type.Valid.goindex", []byte("package pkg\n\ntype Valid struct{}\n"), 0600))
+	require.NoError(t, afero.WriteFile(destFs, "output/pkg/var.Broken.goindex", []byte("package github.com/example/pkg\n\n\tBroken int\n"), 0600))
+	require.NoError(t, afero.WriteFile(destFs, "output/pkg/manifest.json", []byte("{}"), 0600))
+
+	failures, err := VerifyIndexFiles("output")
+	require.NoError(t, err)
+	require.Len(t, failures, 1)
+	assert.Equal(t, "output/pkg/var.Broken.goindex", failures[0].File)
+	assert.Error(t, failures[0].Err)
+}
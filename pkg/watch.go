@@ -0,0 +1,299 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/afero"
+)
+
+// DefaultWatchDebounce is how long Watch waits for changes to settle before re-indexing
+const DefaultWatchDebounce = 300 * time.Millisecond
+
+// Watch runs an initial IndexSourceCodeWithOptions and then keeps the index files in destFolder live:
+// it watches the source tree under opts.Root and pkgPath, waits for changes to settle for the debounce
+// delay, and re-indexes only the packages whose Go files changed, updating or deleting their .goindex
+// files and manifests. It returns when ctx is done. Re-indexed and removed packages, and problems that do
+// not stop watching, are reported through progressCallback.
+func Watch(ctx context.Context, pkgPath, basePkgUrl, destFolder string, opts Options, debounce time.Duration, progressCallback func(ProgressInfo)) error {
+	basePkgUrl, err := resolveBasePkgUrl(basePkgUrl, opts)
+	if err != nil {
+		return err
+	}
+	root := opts.Root
+	if root == "" {
+		root = "."
+	}
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+	if debounce <= 0 {
+		debounce = DefaultWatchDebounce
+	}
+	report := func(progress ProgressInfo) {
+		if progressCallback != nil {
+			progressCallback(progress)
+		}
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to start file watcher: %w", err)
+	}
+	defer func() { _ = watcher.Close() }()
+
+	// Watch before the initial run so no change made while it runs is missed
+	watchedDirs := make(map[string]bool)
+	addWatches := func(dir string) []string {
+		var added []string
+		_ = filepath.WalkDir(dir, func(p string, entry os.DirEntry, err error) error {
+			if err != nil || !entry.IsDir() {
+				return nil
+			}
+			if p != dir && (shouldSkipDirectory(entry.Name()) || isModuleRoot(p)) {
+				return filepath.SkipDir
+			}
+			if err := watcher.Add(p); err != nil {
+				report(ProgressInfo{Warnings: []string{fmt.Sprintf("failed to watch %s: %v", p, err)}})
+				return nil
+			}
+			watchedDirs[p] = true
+			added = append(added, p)
+			return nil
+		})
+		return added
+	}
+	addWatches(filepath.Join(absRoot, filepath.FromSlash(pkgPath)))
+
+	if err := IndexSourceCodeContext(ctx, pkgPath, basePkgUrl, destFolder, opts, progressCallback); err != nil {
+		return err
+	}
+
+	pending := make(map[string]bool)
+	timer := time.NewTimer(debounce)
+	timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			report(ProgressInfo{Warnings: []string{fmt.Sprintf("file watcher error: %v", err)}})
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			dir, affected := affectedPackageDir(event, watchedDirs)
+			if !affected {
+				continue
+			}
+			pending[dir] = true
+			if event.Has(fsnotify.Create) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					// Files may have landed in the new directory tree before it was watched
+					for _, added := range addWatches(event.Name) {
+						pending[added] = true
+					}
+				}
+			}
+			timer.Reset(debounce)
+		case <-timer.C:
+			dirs := make([]string, 0, len(pending))
+			for dir := range pending {
+				dirs = append(dirs, dir)
+			}
+			pending = make(map[string]bool)
+			sort.Strings(dirs)
+
+			for _, dir := range dirs {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				if _, err := os.Stat(dir); err != nil {
+					delete(watchedDirs, dir)
+				}
+				rel, err := filepath.Rel(absRoot, dir)
+				if err != nil || !filepath.IsLocal(rel) {
+					continue
+				}
+				if err := reindexPackage(ctx, filepath.ToSlash(rel), basePkgUrl, destFolder, absRoot, opts, report); err != nil {
+					report(ProgressInfo{Warnings: []string{fmt.Sprintf("failed to re-index %s: %v", dir, err)}})
+				}
+			}
+		}
+	}
+}
+
+// affectedPackageDir returns the package directory whose index an event affects:
+// the directory of a changed Go file, or a watched directory that was created, removed or renamed
+func affectedPackageDir(event fsnotify.Event, watchedDirs map[string]bool) (string, bool) {
+	if event.Has(fsnotify.Chmod) && !event.Has(fsnotify.Write) {
+		return "", false
+	}
+	if strings.HasSuffix(event.Name, ".go") {
+		return filepath.Dir(event.Name), true
+	}
+	if watchedDirs[event.Name] {
+		return event.Name, true
+	}
+	if event.Has(fsnotify.Create) {
+		if info, err := os.Stat(event.Name); err == nil && info.IsDir() && !shouldSkipDirectory(info.Name()) {
+			return event.Name, true
+		}
+	}
+	return "", false
+}
+
+// reindexPackage scans a single package and brings its index files, its manifest, the root manifest and
+// the state file in destFolder up to date. A package that no longer exists or has no symbols left loses
+// its index files. The package is reported to report as re-indexed or removed.
+func reindexPackage(ctx context.Context, pkgPath, basePkgUrl, destFolder, sourceRoot string, opts Options, report func(ProgressInfo)) error {
+	var manifest *Manifest
+	pkgDir := filepath.Join(sourceRoot, filepath.FromSlash(pkgPath))
+	relativePkgPath := pkgPath
+	hasSources := hasGoFiles(pkgDir)
+	if hasSources {
+		pkgInfo, err := scanPackage(ctx, pkgPath, basePkgUrl, opts)
+		if err != nil {
+			return err
+		}
+		pkgUrl := pkgInfo.PkgPath
+		if pkgUrl == "" {
+			pkgUrl = joinPackageUrl(basePkgUrl, pkgPath)
+		}
+		relativePkgPath = strings.TrimPrefix(strings.TrimPrefix(pkgUrl, basePkgUrl), "/")
+		if manifest, err = writePackageIndex(pkgInfo, pkgUrl, basePkgUrl, destFolder, sourceRoot, opts); err != nil {
+			return err
+		}
+	}
+
+	indexDir := path.Clean("./" + relativePkgPath)
+	if err := prunePackageDir(destFolder, indexDir, manifest); err != nil {
+		return err
+	}
+	if err := updateRootManifest(destFolder, indexDir, manifest); err != nil {
+		return err
+	}
+
+	// Record the package's new hash so the next full run skips it
+	// An unreadable state file is replaced
+	state, _ := loadIndexState(destFolder)
+	key := stateKey(pkgPath)
+	_, wasIndexed := state.Packages[key]
+	if !hasSources {
+		delete(state.Packages, key)
+	} else if hash, err := hashPackageSources(pkgDir); err == nil {
+		packageState := PackageState{Hash: hash}
+		if manifest != nil {
+			packageState.IndexDir = indexDir
+		}
+		state.Packages[key] = packageState
+	}
+	if state.Version == "" {
+		state.Version = Version()
+		state.Options = optionsFingerprint(basePkgUrl, opts)
+	}
+	if err := saveIndexState(destFolder, state); err != nil {
+		return err
+	}
+	switch {
+	case manifest != nil:
+		report(ProgressInfo{Current: manifest.Package, Reindexed: []string{manifest.Package}})
+	case wasIndexed:
+		pkgUrl := joinPackageUrl(basePkgUrl, pkgPath)
+		report(ProgressInfo{Current: pkgUrl, Unindexed: []string{pkgUrl}})
+	}
+	return nil
+}
+
+// hasGoFiles reports whether dir holds any Go file
+func hasGoFiles(dir string) bool {
+	entries, err := afero.ReadDir(sourceFs, dir)
+	if err != nil {
+		return false
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".go") {
+			return true
+		}
+	}
+	return false
+}
+
+// prunePackageDir removes the index files in a package's index directory that its manifest does not list.
+// When the package has no symbols left its manifest goes too, along with the directory once it is empty.
+// Subdirectories belong to other packages and are left alone.
+func prunePackageDir(destFolder, indexDir string, manifest *Manifest) error {
+	owned := make(map[string]bool)
+	if manifest != nil {
+		for _, symbol := range manifest.Symbols {
+			owned[path.Base(symbol.IndexFile)] = true
+		}
+	}
+
+	pkgDestDir := filepath.Join(destFolder, filepath.FromSlash(indexDir))
+	entries, err := afero.ReadDir(destFs, pkgDestDir)
+	if err != nil {
+		return nil
+	}
+	remaining := 0
+	for _, entry := range entries {
+		name := entry.Name()
+		// The root manifest lists the other packages, so it stays even when the root package is gone
+		stale := !entry.IsDir() && (strings.HasSuffix(name, ".goindex") && !owned[name] ||
+			manifest == nil && name == manifestFileName && indexDir != ".")
+		if !stale {
+			remaining++
+			continue
+		}
+		if err := destFs.Remove(filepath.Join(pkgDestDir, name)); err != nil {
+			return err
+		}
+	}
+	if remaining == 0 && indexDir != "." {
+		return destFs.Remove(pkgDestDir)
+	}
+	return nil
+}
+
+// updateRootManifest replaces the manifest of the package in indexDir within the root manifest of destFolder,
+// or drops it when manifest is nil
+func updateRootManifest(destFolder, indexDir string, manifest *Manifest) error {
+	previous, err := loadRootManifest(destFolder)
+	if err != nil {
+		return err
+	}
+
+	// Only the links of the other packages are kept; the root package's symbols come from its own manifest
+	root := newRootManifest(nil)
+	if manifest != nil {
+		root = newRootManifest([]*Manifest{manifest})
+	}
+	for _, link := range previous.Packages {
+		if link.Path == indexDir {
+			continue
+		}
+		root.Packages = append(root.Packages, link)
+		if link.Path == "." {
+			root.Package = previous.Package
+			root.Symbols = previous.Symbols
+			root.Collisions = previous.Collisions
+		}
+	}
+	sort.Slice(root.Packages, func(i, j int) bool {
+		return root.Packages[i].Path < root.Packages[j].Path
+	})
+	root.Revision = previous.Revision
+	root.Modules = previous.Modules
+	return saveManifest(destFolder, root)
+}
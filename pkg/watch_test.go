@@ -0,0 +1,89 @@
+package pkg
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prashantv/gostub"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatch_ReindexesChangedPackages(t *testing.T) {
+	stubs := gostub.Stub(&destFs, afero.NewMemMapFs())
+	defer stubs.Reset()
+
+	src := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(src, "go.mod"), []byte("module example.com/watched\n\ngo 1.23\n"), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(src, "a"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "a", "a.go"), []byte("package a\n\nfunc A() {}\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "root.go"), []byte("package watched\n\nfunc Root() {}\n"), 0644))
+
+	// Re-indexed and removed packages are reported through the progress callback
+	var reindexed, unindexed []string
+	var mu sync.Mutex
+	callback := func(progress ProgressInfo) {
+		mu.Lock()
+		defer mu.Unlock()
+		reindexed = append(reindexed, progress.Reindexed...)
+		unindexed = append(unindexed, progress.Unindexed...)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- Watch(ctx, "", "", "output", Options{Root: src}, 20*time.Millisecond, callback)
+	}()
+	defer func() {
+		cancel()
+		assert.ErrorIs(t, <-done, context.Canceled)
+	}()
+
+	indexed := func(path string) func() bool {
+		return func() bool {
+			exists, err := afero.Exists(destFs, path)
+			return err == nil && exists
+		}
+	}
+	require.Eventually(t, indexed("output/a/func.A.goindex"), 30*time.Second, 20*time.Millisecond)
+
+	// A changed file replaces the package's index files
+	require.NoError(t, os.WriteFile(filepath.Join(src, "a", "a.go"), []byte("package a\n\nfunc A2() {}\n"), 0644))
+	require.Eventually(t, indexed("output/a/func.A2.goindex"), 30*time.Second, 20*time.Millisecond)
+	assert.Eventually(t, func() bool { return !indexed("output/a/func.A.goindex")() }, 5*time.Second, 20*time.Millisecond)
+
+	// A new package gets indexed
+	require.NoError(t, os.MkdirAll(filepath.Join(src, "b"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "b", "b.go"), []byte("package b\n\nfunc B() {}\n"), 0644))
+	require.Eventually(t, indexed("output/b/func.B.goindex"), 30*time.Second, 20*time.Millisecond)
+
+	// A removed package loses its index files and its entry in the root manifest
+	require.NoError(t, os.RemoveAll(filepath.Join(src, "a")))
+	require.Eventually(t, func() bool { return !indexed("output/a")() }, 30*time.Second, 20*time.Millisecond)
+	// The state file is saved last, once it drops the package the root manifest is up to date
+	require.Eventually(t, func() bool {
+		state, _ := loadIndexState("output")
+		packages := state.Packages
+		_, hasA := packages["a"]
+		_, hasB := packages["b"]
+		return hasB && !hasA
+	}, 5*time.Second, 20*time.Millisecond)
+	assertRootManifestPackages(t, "example.com/watched", "example.com/watched/b")
+
+	// The package is reported once its state is saved
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(unindexed) > 0
+	}, 5*time.Second, 20*time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Contains(t, reindexed, "example.com/watched/a")
+	assert.Contains(t, reindexed, "example.com/watched/b")
+	assert.Equal(t, []string{"example.com/watched/a"}, unindexed)
+}
@@ -0,0 +1,172 @@
+package pkg
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"github.com/spf13/afero"
+	"golang.org/x/mod/modfile"
+)
+
+// ModuleInfo describes a Go module found under a source root
+type ModuleInfo struct {
+	Path      string // Module path declared in go.mod, e.g. github.com/user/project/tools; for a root inside a module, its import path
+	Dir       string // Module directory relative to the source root, using forward slashes ("." for the root); a module outside the source root is placed under its module path
+	SourceDir string // Absolute directory of a module outside the source root, used by a go.work file; empty for modules inside it
+}
+
+// sourceDir returns the directory holding the module's source, given the source root
+func (m ModuleInfo) sourceDir(root string) string {
+	if m.SourceDir != "" {
+		return m.SourceDir
+	}
+	return filepath.Join(root, filepath.FromSlash(m.Dir))
+}
+
+// FindModules lists the modules under root. When root holds a go.work file its use directives
+// define the modules, resolved against the go.work directory; otherwise the module root belongs to (if any) and every nested module are returned.
+// Directories skipped during package discovery are not searched.
+func FindModules(root string) ([]ModuleInfo, error) {
+	if root == "" {
+		root = "."
+	}
+
+	workPath := filepath.Join(root, "go.work")
+	if content, err := afero.ReadFile(sourceFs, workPath); err == nil {
+		work, err := modfile.ParseWork(workPath, content, nil)
+		if err != nil {
+			return nil, err
+		}
+		var modules []ModuleInfo
+		for _, use := range work.Use {
+			module, err := readWorkModule(root, use.Path)
+			if err != nil {
+				return nil, err
+			}
+			modules = append(modules, module)
+		}
+		sortModules(modules)
+		return modules, nil
+	}
+
+	// The root belongs to its own module or to the module enclosing it
+	var modules []ModuleInfo
+	if rootPkgUrl, err := DetectBasePkgUrl(root); err == nil {
+		modules = append(modules, ModuleInfo{Path: rootPkgUrl, Dir: "."})
+	}
+	nested, err := findNestedModules(root, ".")
+	if err != nil {
+		return nil, err
+	}
+	modules = append(modules, nested...)
+	sortModules(modules)
+	return modules, nil
+}
+
+// findNestedModules recursively collects the modules in subdirectories of dir, not descending into them
+func findNestedModules(root, dir string) ([]ModuleInfo, error) {
+	entries, err := afero.ReadDir(sourceFs, filepath.Join(root, filepath.FromSlash(dir)))
+	if err != nil {
+		return nil, nil
+	}
+
+	var modules []ModuleInfo
+	for _, entry := range entries {
+		if !entry.IsDir() || shouldSkipDirectory(entry.Name()) {
+			continue
+		}
+		subDir := path.Join(dir, entry.Name())
+		if isModuleRoot(filepath.Join(root, filepath.FromSlash(subDir))) {
+			module, err := readModule(root, subDir)
+			if err != nil {
+				return nil, err
+			}
+			modules = append(modules, module)
+			continue
+		}
+		nested, err := findNestedModules(root, subDir)
+		if err != nil {
+			return nil, err
+		}
+		modules = append(modules, nested...)
+	}
+	return modules, nil
+}
+
+// readModule reads the module path from the go.mod in dir, relative to root
+func readModule(root, dir string) (ModuleInfo, error) {
+	goModPath := filepath.Join(root, filepath.FromSlash(dir), "go.mod")
+	content, err := afero.ReadFile(sourceFs, goModPath)
+	if err != nil {
+		return ModuleInfo{}, fmt.Errorf("failed to read %s: %w", goModPath, err)
+	}
+	modulePath := modfile.ModulePath(content)
+	if modulePath == "" {
+		return ModuleInfo{}, fmt.Errorf("no module directive found in %s", goModPath)
+	}
+	return ModuleInfo{Path: modulePath, Dir: dir}, nil
+}
+
+// readWorkModule reads the module of a go.work use directive. A module inside root keeps its relative
+// directory; a module outside it, such as use ../shared, is placed under its module path instead,
+// so its index files stay inside the destination folder.
+func readWorkModule(root, usePath string) (ModuleInfo, error) {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return ModuleInfo{}, err
+	}
+	dir := filepath.FromSlash(usePath)
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(absRoot, dir)
+	}
+	rel, err := filepath.Rel(absRoot, dir)
+	if err != nil {
+		return ModuleInfo{}, err
+	}
+	if rel == "." || filepath.IsLocal(rel) {
+		return readModule(root, path.Clean(filepath.ToSlash(rel)))
+	}
+
+	module, err := readModule(dir, ".")
+	if err != nil {
+		return ModuleInfo{}, err
+	}
+	if !filepath.IsLocal(filepath.FromSlash(module.Path)) {
+		return ModuleInfo{}, fmt.Errorf("module %s used by %s is outside %s and its path cannot name an index directory", module.Path, filepath.Join(root, "go.work"), root)
+	}
+	module.Dir = module.Path
+	module.SourceDir = dir
+	return module, nil
+}
+
+// isModuleRoot reports whether dir holds its own go.mod
+func isModuleRoot(dir string) bool {
+	exists, _ := afero.Exists(sourceFs, filepath.Join(dir, "go.mod"))
+	return exists
+}
+
+// sortModules orders modules by directory so the root module comes first
+func sortModules(modules []ModuleInfo) {
+	sort.Slice(modules, func(i, j int) bool {
+		return modules[i].Dir < modules[j].Dir
+	})
+}
+
+// isWorkspace reports whether the modules found under a source root need to be indexed
+// one by one: the root holds a go.work file or modules are nested inside it
+func isWorkspace(root string, modules []ModuleInfo) bool {
+	if root == "" {
+		root = "."
+	}
+	if exists, _ := afero.Exists(sourceFs, filepath.Join(root, "go.work")); exists {
+		return true
+	}
+	for _, module := range modules {
+		if module.Dir != "." {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,166 @@
+package pkg
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/prashantv/gostub"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindModules_NestedModules(t *testing.T) {
+	mockFs := afero.NewMemMapFs()
+	stub := gostub.Stub(&sourceFs, mockFs)
+	defer stub.Reset()
+
+	require.NoError(t, afero.WriteFile(mockFs, "/repo/go.mod", []byte("module example.com/repo\n"), 0644))
+	require.NoError(t, afero.WriteFile(mockFs, "/repo/tools/go.mod", []byte("module example.com/repo/tools\n"), 0644))
+	require.NoError(t, afero.WriteFile(mockFs, "/repo/tools/inner/go.mod", []byte("module example.com/inner\n"), 0644))
+	require.NoError(t, afero.WriteFile(mockFs, "/repo/api/v2/go.mod", []byte("module example.com/repo/api/v2\n"), 0644))
+	require.NoError(t, afero.WriteFile(mockFs, "/repo/testdata/go.mod", []byte("module example.com/ignored\n"), 0644))
+
+	modules, err := FindModules("/repo")
+	require.NoError(t, err)
+	assert.Equal(t, []ModuleInfo{
+		{Path: "example.com/repo", Dir: "."},
+		{Path: "example.com/repo/api/v2", Dir: "api/v2"},
+		{Path: "example.com/repo/tools", Dir: "tools"},
+	}, modules, "Modules nested in a nested module belong to it and skipped directories are not searched")
+	assert.True(t, isWorkspace("/repo", modules))
+
+	// Package discovery stops at nested modules
+	assert.Equal(t, []string{"api"}, findSubPackages("/repo", ""))
+}
+
+func TestFindModules_GoWork(t *testing.T) {
+	mockFs := afero.NewMemMapFs()
+	stub := gostub.Stub(&sourceFs, mockFs)
+	defer stub.Reset()
+
+	goWork := "go 1.23\n\nuse (\n\t./svc/a\n\t./lib\n)\n"
+	require.NoError(t, afero.WriteFile(mockFs, "/work/go.work", []byte(goWork), 0644))
+	require.NoError(t, afero.WriteFile(mockFs, "/work/svc/a/go.mod", []byte("module example.com/svc/a\n"), 0644))
+	require.NoError(t, afero.WriteFile(mockFs, "/work/lib/go.mod", []byte("module example.com/lib\n"), 0644))
+	require.NoError(t, afero.WriteFile(mockFs, "/work/unused/go.mod", []byte("module example.com/unused\n"), 0644))
+
+	modules, err := FindModules("/work")
+	require.NoError(t, err)
+	assert.Equal(t, []ModuleInfo{
+		{Path: "example.com/lib", Dir: "lib"},
+		{Path: "example.com/svc/a", Dir: "svc/a"},
+	}, modules, "Only modules used by go.work are indexed")
+	assert.True(t, isWorkspace("/work", modules))
+}
+
+func TestFindModules_GoWorkOutsideRoot(t *testing.T) {
+	mockFs := afero.NewMemMapFs()
+	stub := gostub.Stub(&sourceFs, mockFs)
+	defer stub.Reset()
+
+	goWork := "go 1.23\n\nuse (\n\t./app\n\t../shared\n\t/abs/lib\n)\n"
+	require.NoError(t, afero.WriteFile(mockFs, "/work/go.work", []byte(goWork), 0644))
+	require.NoError(t, afero.WriteFile(mockFs, "/work/app/go.mod", []byte("module example.com/app\n"), 0644))
+	require.NoError(t, afero.WriteFile(mockFs, "/shared/go.mod", []byte("module example.com/shared\n"), 0644))
+	require.NoError(t, afero.WriteFile(mockFs, "/abs/lib/go.mod", []byte("module example.com/lib\n"), 0644))
+
+	modules, err := FindModules("/work")
+	require.NoError(t, err)
+	assert.Equal(t, []ModuleInfo{
+		{Path: "example.com/app", Dir: "app"},
+		{Path: "example.com/lib", Dir: "example.com/lib", SourceDir: filepath.FromSlash("/abs/lib")},
+		{Path: "example.com/shared", Dir: "example.com/shared", SourceDir: filepath.FromSlash("/shared")},
+	}, modules, "Modules outside the go.work directory are placed under their module path")
+}
+
+func TestIndexSourceCode_GoWorkSiblingModule(t *testing.T) {
+	srcFs := afero.NewMemMapFs()
+	outFs := afero.NewMemMapFs()
+	stubs := gostub.Stub(&sourceFs, srcFs)
+	stubs.Stub(&destFs, outFs)
+	defer stubs.Reset()
+
+	require.NoError(t, afero.WriteFile(srcFs, "/work/go.work", []byte("go 1.23\n\nuse (\n\t./app\n\t../x\n)\n"), 0644))
+	require.NoError(t, afero.WriteFile(srcFs, "/work/app/go.mod", []byte("module example.com/app\n"), 0644))
+	require.NoError(t, afero.WriteFile(srcFs, "/work/app/app.go", []byte("package app\n\nfunc Run() {}\n"), 0644))
+	require.NoError(t, afero.WriteFile(srcFs, "/x/go.mod", []byte("module example.com/x\n"), 0644))
+	require.NoError(t, afero.WriteFile(srcFs, "/x/x.go", []byte("package x\n\nfunc Shared() {}\n"), 0644))
+
+	require.NoError(t, IndexSourceCodeWithOptions("", "", "/out/index", Options{Root: "/work"}, nil))
+
+	// Nothing is written outside the destination folder
+	err := afero.Walk(outFs, "/", func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			assert.True(t, strings.HasPrefix(filepath.ToSlash(path), "/out/index/"), path)
+		}
+		return err
+	})
+	require.NoError(t, err)
+
+	content, err := afero.ReadFile(outFs, "/out/index/example.com/x/func.Shared.goindex")
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "// Import path: example.com/x\n")
+	exists, err := afero.Exists(outFs, "/out/index/app/func.Run.goindex")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	content, err = afero.ReadFile(outFs, "/out/index/manifest.json")
+	require.NoError(t, err)
+	var rootManifest Manifest
+	require.NoError(t, json.Unmarshal(content, &rootManifest))
+	assert.Equal(t, []ManifestModule{
+		{Path: "example.com/app", Dir: "app", Manifest: "app/manifest.json"},
+		{Path: "example.com/x", Dir: "example.com/x", Manifest: "example.com/x/manifest.json"},
+	}, rootManifest.Modules)
+}
+
+func TestIndexSourceCode_NestedModule(t *testing.T) {
+	stub := gostub.Stub(&destFs, afero.NewMemMapFs())
+	defer stub.Reset()
+
+	require.NoError(t, IndexSourceCodeWithOptions("", "", "output", Options{Root: "testharness"}, nil))
+
+	// The nested module is indexed under its own module path, mirroring its source directory
+	content, err := afero.ReadFile(destFs, "output/nested_module/lib/func.Hello.goindex")
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "// Import path: example.com/nested/lib\n")
+
+	content, err = afero.ReadFile(destFs, "output/nested_module/var.Version.goindex")
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "// Import path: example.com/nested\n")
+
+	// The enclosing module's packages keep their own import paths
+	content, err = afero.ReadFile(destFs, "output/type.User.goindex")
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "// Import path: github.com/lonegunmanb/gophon/pkg/testharness\n")
+
+	content, err = afero.ReadFile(destFs, "output/manifest.json")
+	require.NoError(t, err)
+	var rootManifest Manifest
+	require.NoError(t, json.Unmarshal(content, &rootManifest))
+	assert.Equal(t, []ManifestModule{
+		{Path: "github.com/lonegunmanb/gophon/pkg/testharness", Dir: ".", Manifest: "manifest.json"},
+		{Path: "example.com/nested", Dir: "nested_module", Manifest: "nested_module/manifest.json"},
+	}, rootManifest.Modules)
+	for _, pkgManifest := range rootManifest.Packages {
+		assert.NotContains(t, pkgManifest.Package, "example.com/nested")
+	}
+
+	content, err = afero.ReadFile(destFs, "output/nested_module/manifest.json")
+	require.NoError(t, err)
+	var moduleManifest Manifest
+	require.NoError(t, json.Unmarshal(content, &moduleManifest))
+	assert.Equal(t, "example.com/nested", moduleManifest.Package)
+	require.Len(t, moduleManifest.Packages, 2)
+	assert.Equal(t, "example.com/nested/lib", moduleManifest.Packages[1].Package)
+
+	// Index trees of workspaces load back under the recorded import paths
+	store, err := LoadSymbolStore("output", "")
+	require.NoError(t, err)
+	_, err = store.GetSymbol("example.com/nested/lib", "func", "Hello")
+	assert.NoError(t, err)
+}
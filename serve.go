@@ -0,0 +1,72 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/lonegunmanb/gophon/pkg"
+)
+
+// runServe implements the "serve" subcommand, which answers AI agent requests over MCP
+func runServe(args []string) {
+	serveFlags := flag.NewFlagSet("serve", flag.ExitOnError)
+	var (
+		mcp        = serveFlags.Bool("mcp", false, "Speak the Model Context Protocol over stdio")
+		indexDir   = serveFlags.String("index", "", "Directory of index files generated by gophon; when empty, packages are indexed on the fly")
+		pkgPath    = serveFlags.String("pkg", "", "Package path to index on the fly (e.g., 'testharness' or '' for root)")
+		basePkgUrl = serveFlags.String("base", "", "Base package URL (e.g., 'github.com/lonegunmanb/gophon/pkg'); detected from go.mod when indexing on the fly")
+		rootDir    = serveFlags.String("root", "", "Directory package paths are relative to when indexing on the fly (default: current directory)")
+		noDocs     = serveFlags.Bool("no-doc-comments", false, "Leave doc comments out of symbols indexed on the fly")
+		syntaxOnly = serveFlags.Bool("syntax-only", false, "Parse sources indexed on the fly directly, without the Go toolchain or downloaded dependencies")
+	)
+
+	serveFlags.Usage = func() {
+		_, _ = fmt.Fprintf(os.Stderr, "Usage: %s serve --mcp [options]\n\n", os.Args[0])
+		_, _ = fmt.Fprintf(os.Stderr, "Options:\n")
+		serveFlags.PrintDefaults()
+		_, _ = fmt.Fprintf(os.Stderr, "\nTools:\n")
+		_, _ = fmt.Fprintf(os.Stderr, "  get_symbol(package, kind, name)  Return the index content of a symbol\n")
+		_, _ = fmt.Fprintf(os.Stderr, "  list_package(package)            List the symbols of a package\n")
+		_, _ = fmt.Fprintf(os.Stderr, "  search_symbols(query)            Search symbol names across packages\n")
+	}
+
+	_ = serveFlags.Parse(args)
+
+	if !*mcp {
+		_, _ = fmt.Fprintf(os.Stderr, "Error: --mcp flag is required\n\n")
+		serveFlags.Usage()
+		os.Exit(1)
+	}
+
+	// stdout carries the protocol, so all diagnostics go to stderr
+	logger := log.New(os.Stderr, "gophon: ", 0)
+
+	var (
+		store *pkg.SymbolStore
+		err   error
+	)
+	if *indexDir != "" {
+		absIndexDir, err := filepath.Abs(*indexDir)
+		if err != nil {
+			logger.Fatalf("Failed to resolve index directory: %v", err)
+		}
+		store, err = pkg.LoadSymbolStore(absIndexDir, *basePkgUrl)
+		if err != nil {
+			logger.Fatalf("Failed to load index files: %v", err)
+		}
+	} else {
+		opts := pkg.Options{ExcludeDocComments: *noDocs, Root: *rootDir, SyntaxOnly: *syntaxOnly}
+		store, err = pkg.BuildSymbolStore(*pkgPath, *basePkgUrl, opts, nil)
+		if err != nil {
+			logger.Fatalf("Failed to index packages: %v", err)
+		}
+	}
+
+	logger.Printf("serving %d packages over MCP (stdio)", len(store.Packages()))
+	if err := pkg.ServeMCP(store, os.Stdin, os.Stdout); err != nil {
+		logger.Fatalf("MCP server stopped: %v", err)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/lonegunmanb/gophon/pkg"
+)
+
+// runVerify implements the "verify" subcommand, which checks that every generated index file parses as Go
+func runVerify(args []string) {
+	verifyFlags := flag.NewFlagSet("verify", flag.ExitOnError)
+	destDir := verifyFlags.String("dest", "./index", "Directory of index files generated by gophon")
+
+	verifyFlags.Usage = func() {
+		_, _ = fmt.Fprintf(os.Stderr, "Usage: %s verify [options]\n\n", os.Args[0])
+		_, _ = fmt.Fprintf(os.Stderr, "Parses every .goindex file with go/parser and reports the files that do not parse.\n\n")
+		_, _ = fmt.Fprintf(os.Stderr, "Options:\n")
+		verifyFlags.PrintDefaults()
+	}
+
+	_ = verifyFlags.Parse(args)
+
+	absDestDir, err := filepath.Abs(*destDir)
+	if err != nil {
+		log.Fatalf("Failed to resolve destination directory: %v", err)
+	}
+
+	failures, err := pkg.VerifyIndexFiles(absDestDir)
+	if err != nil {
+		log.Fatalf("Failed to verify index files: %v", err)
+	}
+
+	if len(failures) == 0 {
+		fmt.Printf("✅ All index files in %s parse as valid Go\n", absDestDir)
+		return
+	}
+
+	for _, failure := range failures {
+		_, _ = fmt.Fprintf(os.Stderr, "❌ %v\n", failure.Err)
+	}
+	_, _ = fmt.Fprintf(os.Stderr, "%d index file(s) failed verification\n", len(failures))
+	os.Exit(1)
+}
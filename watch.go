@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/lonegunmanb/gophon/pkg"
+)
+
+// runWatch implements the "watch" subcommand, which indexes a module and then keeps its index files up to date
+func runWatch(args []string) {
+	watchFlags := flag.NewFlagSet("watch", flag.ExitOnError)
+	var (
+		pkgPath    = watchFlags.String("pkg", "", "Package path to watch (e.g., 'testharness' or '' for root)")
+		basePkgUrl = watchFlags.String("base", "", "Base package URL (e.g., 'github.com/lonegunmanb/gophon/pkg'); detected from go.mod when empty")
+		rootDir    = watchFlags.String("root", "", "Directory package paths are relative to (default: current directory)")
+		destDir    = watchFlags.String("dest", "./index", "Destination directory for generated index files")
+		noDocs     = watchFlags.Bool("no-doc-comments", false, "Leave doc comments out of generated index files")
+		syntaxOnly = watchFlags.Bool("syntax-only", false, "Parse sources directly, without the Go toolchain or downloaded dependencies")
+		debounce   = watchFlags.Duration("debounce", pkg.DefaultWatchDebounce, "How long changes must settle before affected packages are re-indexed")
+	)
+
+	watchFlags.Usage = func() {
+		_, _ = fmt.Fprintf(os.Stderr, "Usage: %s watch [options]\n\n", os.Args[0])
+		_, _ = fmt.Fprintf(os.Stderr, "Indexes the module, then re-indexes the packages whose Go files change until interrupted.\n\n")
+		_, _ = fmt.Fprintf(os.Stderr, "Options:\n")
+		watchFlags.PrintDefaults()
+	}
+
+	_ = watchFlags.Parse(args)
+
+	absDestDir, err := filepath.Abs(*destDir)
+	if err != nil {
+		log.Fatalf("Failed to resolve destination directory: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Printf("Indexing into %s, then watching for changes (Ctrl+C to stop)...\n", absDestDir)
+	opts := pkg.Options{ExcludeDocComments: *noDocs, Root: *rootDir, SyntaxOnly: *syntaxOnly}
+	err = pkg.Watch(ctx, *pkgPath, *basePkgUrl, absDestDir, opts, *debounce, func(progress pkg.ProgressInfo) {
+		for _, warning := range progress.Warnings {
+			fmt.Printf("Warning: %s\n", warning)
+		}
+		for _, filePath := range progress.Pruned {
+			fmt.Printf("Removed %s\n", filePath)
+		}
+		for _, pkgUrl := range progress.Reindexed {
+			fmt.Printf("Re-indexed %s\n", pkgUrl)
+		}
+		for _, pkgUrl := range progress.Unindexed {
+			fmt.Printf("Removed index of %s\n", pkgUrl)
+		}
+	})
+	if err != nil && !errors.Is(err, context.Canceled) {
+		log.Fatalf("Failed to watch source code: %v", err)
+	}
+	fmt.Printf("Stopped watching\n")
+}